@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/kckecheng/storagemetric/dell/emc/powermax"
+	"github.com/kckecheng/storagemetric/dell/emc/unity"
+	"github.com/kckecheng/storagemetric/utils"
+)
+
+// Credential Username and password of an array, the password is read from the environment variable
+// named by PasswordEnv if it is not specified in the configuration file
+type Credential struct {
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	PasswordEnv string `json:"passwordEnv"`
+}
+
+// UnityConfig Unity to collect
+type UnityConfig struct {
+	Server string `json:"server"`
+	Credential
+}
+
+// PowerMaxConfig PowerMax to collect
+type PowerMaxConfig struct {
+	Server string `json:"server"`
+	Port   string `json:"port"`
+	SymmID string `json:"symmid"`
+	Credential
+}
+
+// Config Arrays to collect, one entry per array
+type Config struct {
+	Unity    []UnityConfig    `json:"unity"`
+	PowerMax []PowerMaxConfig `json:"powermax"`
+}
+
+// Resolve the password from the environment when it is not set in the configuration file
+func (c Credential) password() string {
+	if c.Password == "" && c.PasswordEnv != "" {
+		return os.Getenv(c.PasswordEnv)
+	}
+	return c.Password
+}
+
+// LoadConfig Load the configuration file in JSON
+func LoadConfig(path string) (Config, error) {
+	var config Config
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("Fail to read configuration %s: %s", path, err.Error())
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("Fail to parse configuration %s: %s", path, err.Error())
+	}
+	return config, nil
+}
+
+// Targets Build a target for each array of the configuration
+func (config Config) Targets(tlsOpts utils.TLSOptions) ([]*Target, error) {
+	var targets []*Target
+
+	for _, u := range config.Unity {
+		password := u.password()
+		if utils.EmptyStrExists(u.Server, u.Username, password) {
+			return nil, fmt.Errorf("Server, username and password of Unity %s must be specified", u.Server)
+		}
+		targets = append(targets, &Target{
+			Name:      "unity/" + u.Server,
+			Collector: unity.NewCollector(u.Server, u.Username, password).WithOptions(unity.WithTLS(tlsOpts), unity.WithRetry(utils.DefaultRetryPolicy())),
+		})
+	}
+
+	for _, p := range config.PowerMax {
+		password := p.password()
+		if utils.EmptyStrExists(p.Server, p.Username, password, p.SymmID) {
+			return nil, fmt.Errorf("Server, username, password and symmid of PowerMax %s must be specified", p.Server)
+		}
+		targets = append(targets, &Target{
+			Name:      "powermax/" + p.SymmID,
+			Collector: powermax.NewCollector(p.Server, p.Port, p.Username, password, p.SymmID).WithOptions(powermax.WithTLS(tlsOpts), powermax.WithRetry(utils.DefaultRetryPolicy())),
+		})
+	}
+
+	if len(targets) == 0 {
+		return nil, errors.New("At least one Unity or PowerMax must be specified")
+	}
+	return targets, nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kckecheng/storagemetric/storage"
+	"github.com/kckecheng/storagemetric/utils"
+)
+
+const namespace = "storagemetric"
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+var camelBoundary = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+var acronymBoundary = regexp.MustCompile(`([A-Z]+)([A-Z][a-z])`)
+
+// Target A named array to be collected
+type Target struct {
+	Name      string
+	Collector storage.Collector
+	connected bool
+}
+
+// Exporter Collect samples from targets periodically and expose them in Prometheus text format
+type Exporter struct {
+	targets []*Target
+	window  time.Duration
+	mutex   sync.RWMutex
+	samples []storage.Sample
+	up      []bool
+	updated time.Time
+}
+
+// NewExporter Init an exporter, samples within the latest window are collected for each update
+func NewExporter(window time.Duration, targets ...*Target) *Exporter {
+	return &Exporter{targets: targets, window: window}
+}
+
+// Update Collect samples from all targets, a target failing to connect is retried on the next update
+func (e *Exporter) Update() {
+	e.UpdateContext(context.Background())
+}
+
+// UpdateContext Collect samples from all targets, collections still running are aborted once ctx is done
+func (e *Exporter) UpdateContext(ctx context.Context) {
+	to := time.Now()
+	from := to.Add(-e.window)
+
+	var samples []storage.Sample
+	// Whether each target is collected, published together with the samples
+	up := make([]bool, len(e.targets))
+	for i, target := range e.targets {
+		if !target.connected {
+			if err := target.Collector.Connect(); err != nil {
+				utils.Log("error", fmt.Sprintf("Fail to connect %s due to %s", target.Name, err.Error()))
+				continue
+			}
+			target.connected = true
+		}
+
+		var ret []storage.Sample
+		var err error
+		if collector, ok := target.Collector.(storage.ContextCollector); ok {
+			ret, err = collector.CollectContext(ctx, from, to)
+		} else {
+			ret, err = target.Collector.Collect(from, to)
+		}
+		if err != nil {
+			utils.Log("error", fmt.Sprintf("Fail to collect metrics from %s due to %s", target.Name, err.Error()))
+			// Force a new login on the next update in case the session expired
+			target.Collector.Close()
+			target.connected = false
+			continue
+		}
+		up[i] = true
+		samples = append(samples, ret...)
+	}
+
+	e.mutex.Lock()
+	e.samples = samples
+	e.up = up
+	e.updated = to
+	e.mutex.Unlock()
+}
+
+// Run Update the exporter every interval until stop is closed, an update is aborted if it lasts
+// longer than interval or stop is closed
+func (e *Exporter) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	update := func() {
+		updateCtx, updateCancel := context.WithTimeout(ctx, interval)
+		defer updateCancel()
+		e.UpdateContext(updateCtx)
+	}
+
+	update()
+	for {
+		select {
+		case <-ticker.C:
+			update()
+		case <-stop:
+			for _, target := range e.targets {
+				if target.connected {
+					target.Collector.Close()
+				}
+			}
+			return
+		}
+	}
+}
+
+// ServeHTTP Serve the latest samples
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	e.Write(w)
+}
+
+// Write Write the latest samples in Prometheus text exposition format
+func (e *Exporter) Write(w io.Writer) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	type family struct {
+		help   string
+		series map[string]storage.Sample
+	}
+	families := map[string]*family{}
+	for _, sample := range e.samples {
+		name := metricName(sample.Kind, sample.Metric)
+		f, ok := families[name]
+		if !ok {
+			help := fmt.Sprintf("%s of %s", sample.Metric, sample.Kind)
+			if sample.Unit != "" {
+				help += fmt.Sprintf(" (%s)", sample.Unit)
+			}
+			f = &family{help: help, series: map[string]storage.Sample{}}
+			families[name] = f
+		}
+		// Keep only the latest sample of each series
+		labels := formatLabels(sample.Labels)
+		if prev, ok := f.series[labels]; !ok || prev.Timestamp.Before(sample.Timestamp) {
+			f.series[labels] = sample
+		}
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := families[name]
+		fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(f.help))
+		fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+		series := make([]string, 0, len(f.series))
+		for labels := range f.series {
+			series = append(series, labels)
+		}
+		sort.Strings(series)
+		for _, labels := range series {
+			fmt.Fprintf(w, "%s%s %g\n", name, labels, f.series[labels].Value)
+		}
+	}
+
+	upName := namespace + "_up"
+	fmt.Fprintf(w, "# HELP %s Whether the last collection from the target succeeded\n", upName)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", upName)
+	for i, target := range e.targets {
+		up := 0
+		if i < len(e.up) && e.up[i] {
+			up = 1
+		}
+		fmt.Fprintf(w, "%s%s %d\n", upName, formatLabels(map[string]string{"target": target.Name}), up)
+	}
+}
+
+// Compose a Prometheus metric name, e.g. array + HostMBReads -> storagemetric_array_host_mb_reads
+func metricName(kind string, metric string) string {
+	kind = snakeCase(kind)
+	metric = snakeCase(strings.Replace(metric, "*", "", -1))
+	metric = strings.TrimPrefix(metric, kind+"_")
+	return namespace + "_" + kind + "_" + metric
+}
+
+func snakeCase(s string) string {
+	// Keep plural acronyms such as IOs in one word
+	s = strings.Replace(s, "IOs", "Ios", -1)
+	s = acronymBoundary.ReplaceAllString(s, "${1}_${2}")
+	s = camelBoundary.ReplaceAllString(s, "${1}_${2}")
+	s = invalidNameChars.ReplaceAllString(s, "_")
+	return strings.Trim(strings.ToLower(s), "_")
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", snakeCase(k), escapeLabelValue(labels[k])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
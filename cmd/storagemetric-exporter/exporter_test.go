@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kckecheng/storagemetric/dell/emc/powermax"
+	"github.com/kckecheng/storagemetric/dell/emc/unity"
+	"github.com/kckecheng/storagemetric/utils"
+)
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// Stand-in for PowerMax Unisphere REST API
+func fakePowerMax() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/univmax/restapi/system/symmetrix/000197900123", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{"symmetrixId": "000197900123"})
+	})
+	mux.HandleFunc("/univmax/restapi/performance/StorageGroup/keys", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"storageGroupInfo": []map[string]interface{}{{"storageGroupId": "oracle_sg"}},
+		})
+	})
+	mux.HandleFunc("/univmax/restapi/performance/Array/metrics", func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now().Unix() * 1000
+		writeJSON(w, map[string]interface{}{
+			"resultList": map[string]interface{}{
+				"result": []map[string]interface{}{{"HostIOs": 1200.0, "FEUtilization": 35.5, "timestamp": ts}},
+			},
+		})
+	})
+	mux.HandleFunc("/univmax/restapi/performance/StorageGroup/metrics", func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now().Unix() * 1000
+		writeJSON(w, map[string]interface{}{
+			"resultList": map[string]interface{}{
+				"result": []map[string]interface{}{{"HostMBReads": 42.0, "timestamp": ts}},
+			},
+		})
+	})
+	return httptest.NewTLSServer(mux)
+}
+
+// Stand-in for Unity REST API
+func fakeUnity() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/types/loginSessionInfo/instances", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("EMC-CSRF-TOKEN", "token")
+		writeJSON(w, map[string]interface{}{"entries": []interface{}{}})
+	})
+	mux.HandleFunc("/api/types/loginSessionInfo/action/logout", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/api/types/system/instances", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"entries": []interface{}{map[string]interface{}{"content": map[string]string{"id": "0", "serialNumber": "FCNCH0972C1234"}}},
+		})
+	})
+	mux.HandleFunc("/api/types/storageProcessor/instances", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"entries": []interface{}{
+				map[string]interface{}{"content": map[string]string{"id": "spa", "name": "SP A"}},
+				map[string]interface{}{"content": map[string]string{"id": "spb", "name": "SP B"}},
+			},
+		})
+	})
+	mux.HandleFunc("/api/types/metricValue/instances", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"entries": []interface{}{
+				map[string]interface{}{"content": map[string]interface{}{
+					"path":      "sp.*.cpu.summary.utilization",
+					"timestamp": time.Now().Add(-10 * time.Second).UTC().Format(time.RFC3339),
+					"values":    map[string]interface{}{"spa": 12.5, "spb": "7.25"},
+				}},
+			},
+		})
+	})
+	return httptest.NewTLSServer(mux)
+}
+
+func TestExporter(t *testing.T) {
+	pmaxServer := fakePowerMax()
+	defer pmaxServer.Close()
+	unityServer := fakeUnity()
+	defer unityServer.Close()
+
+	pmaxAddr := strings.Split(strings.TrimPrefix(pmaxServer.URL, "https://"), ":")
+	unityAddr := strings.TrimPrefix(unityServer.URL, "https://")
+
+	exporter := NewExporter(time.Minute,
+		&Target{Name: "powermax", Collector: powermax.NewCollector(pmaxAddr[0], pmaxAddr[1], "smc", "smc", "000197900123").WithOptions(powermax.WithInsecure())},
+		&Target{Name: "unity", Collector: unity.NewCollector(unityAddr, "admin", "password", "sp.*.cpu.summary.utilization").WithOptions(unity.WithInsecure())},
+		&Target{Name: "down", Collector: unity.NewCollector("127.0.0.1:1", "admin", "password")},
+	)
+	exporter.Update()
+
+	rec := httptest.NewRecorder()
+	exporter.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("Unexpected content type %s", rec.Header().Get("Content-Type"))
+	}
+
+	body := rec.Body.String()
+	t.Log(body)
+	expected := []string{
+		"# HELP storagemetric_array_host_ios HostIOs of array (IO/s)",
+		"# TYPE storagemetric_array_host_ios gauge",
+		`storagemetric_array_host_ios{array="000197900123"} 1200`,
+		`storagemetric_array_fe_utilization{array="000197900123"} 35.5`,
+		`storagemetric_storagegroup_host_mb_reads{array="000197900123",storage_group="oracle_sg"} 42`,
+		"# TYPE storagemetric_sp_cpu_summary_utilization gauge",
+		`storagemetric_sp_cpu_summary_utilization{array="FCNCH0972C1234",sp="spa"} 12.5`,
+		`storagemetric_sp_cpu_summary_utilization{array="FCNCH0972C1234",sp="spb"} 7.25`,
+		`storagemetric_up{target="powermax"} 1`,
+		`storagemetric_up{target="unity"} 1`,
+		`storagemetric_up{target="down"} 0`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Missing line: %s", line)
+		}
+	}
+}
+
+// Scrapes must not race with updates
+func TestConcurrentUpdate(t *testing.T) {
+	unityServer := fakeUnity()
+	defer unityServer.Close()
+
+	exporter := NewExporter(time.Minute,
+		&Target{Name: "unity", Collector: unity.NewCollector(strings.TrimPrefix(unityServer.URL, "https://"), "admin", "password", "sp.*.cpu.summary.utilization").WithOptions(unity.WithInsecure())},
+	)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			exporter.Update()
+		}
+	}()
+	for writing := true; writing; {
+		select {
+		case <-done:
+			writing = false
+		default:
+			var buf bytes.Buffer
+			exporter.Write(&buf)
+		}
+	}
+
+	var buf bytes.Buffer
+	exporter.Write(&buf)
+	if !strings.Contains(buf.String(), `storagemetric_up{target="unity"} 1`) {
+		t.Errorf("Unexpected output %s", buf.String())
+	}
+}
+
+func TestMetricName(t *testing.T) {
+	cases := map[[2]string]string{
+		{"array", "HostMBReads"}:                 "storagemetric_array_host_mb_reads",
+		{"storagegroup", "ReadResponseTime"}:     "storagemetric_storagegroup_read_response_time",
+		{"sp", "sp.*.storage.summary.readsRate"}: "storagemetric_sp_storage_summary_reads_rate",
+	}
+	for in, out := range cases {
+		if name := metricName(in[0], in[1]); name != out {
+			t.Errorf("metricName(%s, %s) = %s, expected %s", in[0], in[1], name, out)
+		}
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString(formatLabels(map[string]string{"storageGroup": "a\"b\\c\nd"}))
+	if buf.String() != `{storage_group="a\"b\\c\nd"}` {
+		t.Errorf("Unexpected labels %s", buf.String())
+	}
+}
+
+func TestConfig(t *testing.T) {
+	f, err := ioutil.TempFile("", "storagemetric-*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	// Commas in passwords must be kept as is
+	f.WriteString(`{
+		"unity": [{"server": "10.0.0.1", "username": "admin", "passwordEnv": "STORAGEMETRIC_TEST_PASSWORD"}],
+		"powermax": [{"server": "10.0.0.2", "port": "8443", "username": "smc", "password": "p,ss", "symmid": "000197900123"}]
+	}`)
+	f.Close()
+
+	os.Setenv("STORAGEMETRIC_TEST_PASSWORD", "a,b")
+	defer os.Unsetenv("STORAGEMETRIC_TEST_PASSWORD")
+
+	config, err := LoadConfig(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Unity[0].password() != "a,b" || config.PowerMax[0].password() != "p,ss" {
+		t.Errorf("Unexpected passwords in %+v", config)
+	}
+	targets, err := config.Targets(utils.TLSOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 || targets[0].Name != "unity/10.0.0.1" || targets[1].Name != "powermax/000197900123" {
+		t.Errorf("Unexpected targets %+v", targets)
+	}
+
+	os.Unsetenv("STORAGEMETRIC_TEST_PASSWORD")
+	if _, err := config.Targets(utils.TLSOptions{}); err == nil {
+		t.Error("Expect an error without the Unity password")
+	}
+	if _, err := (Config{}).Targets(utils.TLSOptions{}); err == nil {
+		t.Error("Expect an error without any array")
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/kckecheng/storagemetric/utils"
+)
+
+func main() {
+	configFile := flag.String("config", "", "Configuration file in JSON listing the Unity and PowerMax arrays to collect")
+	listen := flag.String("listen", ":9690", "Address to serve metrics on")
+	interval := flag.Duration("interval", time.Minute, "Interval to collect metrics")
+	window := flag.Duration("window", 10*time.Minute, "Time range of samples requested on each collection, Unisphere keeps performance data at 5 minute granularity")
+	logfile := flag.String("logfile", "", "Log file, storagemetric.log under the temporary directory as default")
+	loglevel := flag.String("loglevel", "info", "Log level")
+	insecure := flag.Bool("insecure", false, "Skip the verification of array certificates")
+	cacert := flag.String("cacert", "", "CA bundle to verify array certificates, the system CA pool as default")
+	flag.Parse()
+
+	utils.InitLogger(*logfile, *loglevel)
+
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+	targets, err := config.Targets(utils.TLSOptions{Insecure: *insecure, CAFile: *cacert})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	exporter := NewExporter(*window, targets...)
+	go exporter.Run(*interval, nil)
+
+	http.Handle("/metrics", exporter)
+	utils.Log("info", fmt.Sprintf("Serve metrics on %s/metrics", *listen))
+	if err := http.ListenAndServe(*listen, nil); err != nil {
+		utils.Log("fatal", err.Error())
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kckecheng/storagemetric/dell/emc/unity"
+	"github.com/kckecheng/storagemetric/utils"
+)
+
+const usage = `Usage: storagemetric <command> [options]
+
+Commands:
+  unity-queries list      List metric real time queries on a Unity
+  unity-queries cleanup   Delete metric real time queries on a Unity
+
+Run "storagemetric <command> -h" for the options of a command.
+`
+
+// Add Unity login options to a flag set
+func unityFlags(fs *flag.FlagSet) (*string, *string, *string) {
+	server := fs.String("server", "", "Unity IP/FQDN")
+	username := fs.String("username", "admin", "Unity user name, admin as default")
+	password := fs.String("password", "", "Unity user password")
+	return server, username, password
+}
+
+// Add TLS verification options to a flag set
+func tlsFlags(fs *flag.FlagSet) (*bool, *string) {
+	insecure := fs.Bool("insecure", false, "Skip the verification of the server certificate")
+	cacert := fs.String("cacert", "", "CA bundle to verify the server certificate, the system CA pool as default")
+	return insecure, cacert
+}
+
+func printQueries(queries []unity.MetricRealTimeQuery) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tINTERVAL\tEXPIRATION\tPATHS")
+	for _, query := range queries {
+		c := query.Content
+		fmt.Fprintf(w, "%d\t%d\t%s\t%s\n", c.Id, c.Interval, c.Expiration.Format(time.RFC3339), strings.Join(c.Paths, ","))
+	}
+	w.Flush()
+}
+
+func unityQueries(args []string) error {
+	if len(args) == 0 || (args[0] != "list" && args[0] != "cleanup") {
+		return errors.New(usage)
+	}
+	action := args[0]
+
+	fs := flag.NewFlagSet("unity-queries "+action, flag.ExitOnError)
+	server, username, password := unityFlags(fs)
+	insecure, cacert := tlsFlags(fs)
+	paths := fs.String("paths", "", "Only delete queries requesting exactly these comma separated paths")
+	interval := fs.Int("interval", 0, "Only delete queries with this interval in seconds")
+	expiresWithin := fs.Duration("expires-within", 0, "Only delete queries expiring within this duration")
+	all := fs.Bool("all", false, "Delete all queries when no other filter is specified")
+	dryRun := fs.Bool("dry-run", false, "Only list the queries to be deleted")
+	fs.Parse(args[1:])
+
+	if utils.EmptyStrExists(*server, *username, *password) {
+		fs.PrintDefaults()
+		return errors.New("server, username, and password must all be specified")
+	}
+
+	var filter unity.QueryCleanupFilter
+	if *paths != "" {
+		filter.Paths = strings.Split(*paths, ",")
+	}
+	filter.Interval = *interval
+	if *expiresWithin != 0 {
+		filter.ExpiresBefore = time.Now().Add(*expiresWithin)
+	}
+	if action == "cleanup" && !*all && len(filter.Paths) == 0 && filter.Interval == 0 && filter.ExpiresBefore.IsZero() {
+		return errors.New("specify a filter or -all to delete all queries")
+	}
+
+	unityBox, err := unity.New(*server, *username, *password, unity.WithTLS(utils.TLSOptions{Insecure: *insecure, CAFile: *cacert}))
+	if err != nil {
+		return err
+	}
+	defer unityBox.Destroy()
+
+	queries, err := unityBox.ListMetricRealTimeQueries()
+	if err != nil {
+		return err
+	}
+	if action == "list" {
+		printQueries(queries)
+		return nil
+	}
+
+	if *dryRun {
+		var matched []unity.MetricRealTimeQuery
+		for _, query := range queries {
+			if filter.Match(query) {
+				matched = append(matched, query)
+			}
+		}
+		printQueries(matched)
+		return nil
+	}
+
+	deleted, err := unityBox.CleanupMetricRealTimeQueries(filter)
+	printQueries(deleted)
+	return err
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "unity-queries":
+		err = unityQueries(os.Args[2:])
+	default:
+		err = errors.New(usage)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
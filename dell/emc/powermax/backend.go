@@ -0,0 +1,165 @@
+package powermax
+
+import "time"
+
+type BEDirectorMetric struct {
+	PercentBusy float64 `json:"PercentBusy"`
+	IOs         float64 `json:"IOs"`
+	Reads       float64 `json:"Reads"`
+	Writes      float64 `json:"Writes"`
+	MBs         float64 `json:"MBs"`
+	MBRead      float64 `json:"MBRead"`
+	MBWritten   float64 `json:"MBWritten"`
+	Timestamp   int64   `json:"timestamp"`
+}
+
+type RDFDirectorMetric struct {
+	PercentBusy      float64 `json:"PercentBusy"`
+	IOs              float64 `json:"IOs"`
+	Reads            float64 `json:"Reads"`
+	Writes           float64 `json:"Writes"`
+	MBs              float64 `json:"MBs"`
+	MBRead           float64 `json:"MBRead"`
+	MBWritten        float64 `json:"MBWritten"`
+	AvgIOServiceTime float64 `json:"AvgIOServiceTime"`
+	Timestamp        int64   `json:"timestamp"`
+}
+
+// DiskMetric Metric of a disk or a disk group
+type DiskMetric struct {
+	PercentBusy       float64 `json:"PercentBusy"`
+	IOs               float64 `json:"IOs"`
+	Reads             float64 `json:"Reads"`
+	Writes            float64 `json:"Writes"`
+	MBs               float64 `json:"MBs"`
+	MBRead            float64 `json:"MBRead"`
+	MBWritten         float64 `json:"MBWritten"`
+	AvgResponseTime   float64 `json:"AvgResponseTime"`
+	ReadResponseTime  float64 `json:"ReadResponseTime"`
+	WriteResponseTime float64 `json:"WriteResponseTime"`
+	Timestamp         int64   `json:"timestamp"`
+}
+
+// DiskGroupMetric Metric of a disk group, which has the same metrics as a disk
+type DiskGroupMetric = DiskMetric
+
+type SRPMetric struct {
+	HostIOs           float64 `json:"HostIOs"`
+	HostReads         float64 `json:"HostReads"`
+	HostWrites        float64 `json:"HostWrites"`
+	HostMBs           float64 `json:"HostMBs"`
+	HostMBReads       float64 `json:"HostMBReads"`
+	HostMBWritten     float64 `json:"HostMBWritten"`
+	ResponseTime      float64 `json:"ResponseTime"`
+	ReadResponseTime  float64 `json:"ReadResponseTime"`
+	WriteResponseTime float64 `json:"WriteResponseTime"`
+	Timestamp         int64   `json:"timestamp"`
+}
+
+// Metrics shared by disks and disk groups
+var diskMetrics = []string{"PercentBusy", "IOs", "Reads", "Writes", "MBs", "MBRead", "MBWritten", "AvgResponseTime", "ReadResponseTime", "WriteResponseTime"}
+
+var (
+	beDirectorQuery  = objectQuery{"BEDirector", "directorId", []string{"PercentBusy", "IOs", "Reads", "Writes", "MBs", "MBRead", "MBWritten"}}
+	rdfDirectorQuery = objectQuery{"RDFDirector", "directorId", []string{"PercentBusy", "IOs", "Reads", "Writes", "MBs", "MBRead", "MBWritten", "AvgIOServiceTime"}}
+	diskGroupQuery   = objectQuery{"DiskGroup", "diskGroupId", diskMetrics}
+	diskQuery        = objectQuery{"Disk", "diskId", diskMetrics}
+	srpQuery         = objectQuery{"SRP", "srpId", []string{"HostIOs", "HostReads", "HostWrites", "HostMBs", "HostMBReads", "HostMBWritten", "ResponseTime", "ReadResponseTime", "WriteResponseTime"}}
+)
+
+// GetBEDirectors List available BE directors
+func (pmax *PowerMax) GetBEDirectors() ([]string, error) {
+	return pmax.queryKeys("BEDirector", nil, "beDirectorInfo", "directorId")
+}
+
+// GetBEDirectorMetricSeries Get all metric samples of a BE director within the time range ordered by timestamp,
+// ErrNoData is returned if there is no sample
+func (pmax *PowerMax) GetBEDirectorMetricSeries(dir string, from time.Time, to time.Time) ([]BEDirectorMetric, error) {
+	var metrics []BEDirectorMetric
+	err := pmax.queryObject(beDirectorQuery.with(dir, from, to), &metrics, nil)
+	return metrics, err
+}
+
+// GetBEDirectorMetric Get the average metric of a BE director within the time range with the latest timestamp,
+// ErrNoData is returned if there is no sample
+func (pmax *PowerMax) GetBEDirectorMetric(dir string, from time.Time, to time.Time) (BEDirectorMetric, error) {
+	var metric BEDirectorMetric
+	err := pmax.queryObject(beDirectorQuery.with(dir, from, to), &[]BEDirectorMetric{}, &metric)
+	return metric, err
+}
+
+// GetRDFDirectors List available RDF directors
+func (pmax *PowerMax) GetRDFDirectors() ([]string, error) {
+	return pmax.queryKeys("RDFDirector", nil, "rdfDirectorInfo", "directorId")
+}
+
+// GetRDFDirectorMetricSeries Same as GetBEDirectorMetricSeries for a RDF director
+func (pmax *PowerMax) GetRDFDirectorMetricSeries(dir string, from time.Time, to time.Time) ([]RDFDirectorMetric, error) {
+	var metrics []RDFDirectorMetric
+	err := pmax.queryObject(rdfDirectorQuery.with(dir, from, to), &metrics, nil)
+	return metrics, err
+}
+
+// GetRDFDirectorMetric Same as GetBEDirectorMetric for a RDF director
+func (pmax *PowerMax) GetRDFDirectorMetric(dir string, from time.Time, to time.Time) (RDFDirectorMetric, error) {
+	var metric RDFDirectorMetric
+	err := pmax.queryObject(rdfDirectorQuery.with(dir, from, to), &[]RDFDirectorMetric{}, &metric)
+	return metric, err
+}
+
+// GetDiskGroups List available disk groups
+func (pmax *PowerMax) GetDiskGroups() ([]string, error) {
+	return pmax.queryKeys("DiskGroup", nil, "diskGroupInfo", "diskGroupId")
+}
+
+// GetDiskGroupMetricSeries Same as GetBEDirectorMetricSeries for a disk group
+func (pmax *PowerMax) GetDiskGroupMetricSeries(group string, from time.Time, to time.Time) ([]DiskMetric, error) {
+	var metrics []DiskMetric
+	err := pmax.queryObject(diskGroupQuery.with(group, from, to), &metrics, nil)
+	return metrics, err
+}
+
+// GetDiskGroupMetric Same as GetBEDirectorMetric for a disk group
+func (pmax *PowerMax) GetDiskGroupMetric(group string, from time.Time, to time.Time) (DiskMetric, error) {
+	var metric DiskMetric
+	err := pmax.queryObject(diskGroupQuery.with(group, from, to), &[]DiskMetric{}, &metric)
+	return metric, err
+}
+
+// GetDisks List available disks
+func (pmax *PowerMax) GetDisks() ([]string, error) {
+	return pmax.queryKeys("Disk", nil, "diskInfo", "diskId")
+}
+
+// GetDiskMetricSeries Same as GetBEDirectorMetricSeries for a disk
+func (pmax *PowerMax) GetDiskMetricSeries(disk string, from time.Time, to time.Time) ([]DiskMetric, error) {
+	var metrics []DiskMetric
+	err := pmax.queryObject(diskQuery.with(disk, from, to), &metrics, nil)
+	return metrics, err
+}
+
+// GetDiskMetric Same as GetBEDirectorMetric for a disk
+func (pmax *PowerMax) GetDiskMetric(disk string, from time.Time, to time.Time) (DiskMetric, error) {
+	var metric DiskMetric
+	err := pmax.queryObject(diskQuery.with(disk, from, to), &[]DiskMetric{}, &metric)
+	return metric, err
+}
+
+// GetSRPs List available storage resource pools
+func (pmax *PowerMax) GetSRPs() ([]string, error) {
+	return pmax.queryKeys("SRP", nil, "srpInfo", "srpId")
+}
+
+// GetSRPMetricSeries Same as GetBEDirectorMetricSeries for a storage resource pool
+func (pmax *PowerMax) GetSRPMetricSeries(srp string, from time.Time, to time.Time) ([]SRPMetric, error) {
+	var metrics []SRPMetric
+	err := pmax.queryObject(srpQuery.with(srp, from, to), &metrics, nil)
+	return metrics, err
+}
+
+// GetSRPMetric Same as GetBEDirectorMetric for a storage resource pool
+func (pmax *PowerMax) GetSRPMetric(srp string, from time.Time, to time.Time) (SRPMetric, error) {
+	var metric SRPMetric
+	err := pmax.queryObject(srpQuery.with(srp, from, to), &[]SRPMetric{}, &metric)
+	return metric, err
+}
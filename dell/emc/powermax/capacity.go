@@ -0,0 +1,94 @@
+package powermax
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Efficiency Data reduction and space saving ratios, e.g. 3.5 stands for 3.5:1
+type Efficiency struct {
+	OverallEfficiencyRatio          float64 `json:"overall_efficiency_ratio_to_one"`
+	DataReductionRatio              float64 `json:"data_reduction_ratio_to_one"`
+	DataReductionEnabledPercent     float64 `json:"data_reduction_enabled_percent"`
+	VirtualProvisioningSavingsRatio float64 `json:"virtual_provisioning_savings_ratio_to_one"`
+	SnapshotSavingsRatio            float64 `json:"snapshot_savings_ratio_to_one"`
+}
+
+// Capacity Usable, used and subscribed capacity in TB
+type Capacity struct {
+	UsableTotalTB      float64 `json:"usable_total_tb"`
+	UsableUsedTB       float64 `json:"usable_used_tb"`
+	SubscribedTotalTB  float64 `json:"subscribed_total_tb"`
+	SnapshotModifiedTB float64 `json:"snapshot_modified_tb"`
+}
+
+type SRPCapacity struct {
+	SrpId              string     `json:"srpId"`
+	ReservedCapPercent float64    `json:"reserved_cap_percent"`
+	Capacity           Capacity   `json:"srp_capacity"`
+	Efficiency         Efficiency `json:"srp_efficiency"`
+}
+
+type StorageGroupCapacity struct {
+	StorageGroupId        string  `json:"storageGroupId"`
+	Srp                   string  `json:"srp"`
+	NumOfVols             int     `json:"num_of_vols"`
+	CapGB                 float64 `json:"cap_gb"`
+	VPSavedPercent        float64 `json:"vp_saved_percent"`
+	UnreducibleDataGB     float64 `json:"unreducible_data_gb"`
+	CompressionRatioToOne float64 `json:"compression_ratio_to_one"`
+	// UsedGB Capacity allocated from the SRP, derived from CapGB and VPSavedPercent
+	UsedGB float64 `json:"-"`
+}
+
+type ArrayCapacity struct {
+	SymmetrixId string     `json:"symmetrixId"`
+	Capacity    Capacity   `json:"system_capacity"`
+	Efficiency  Efficiency `json:"system_efficiency"`
+}
+
+// GetSRPCapacity Get capacity and efficiency of a storage resource pool
+func (pmax *PowerMax) GetSRPCapacity(srp string) (SRPCapacity, error) {
+	var capacity SRPCapacity
+	err := pmax.Request("GET", fmt.Sprintf("/univmax/restapi/sloprovisioning/symmetrix/%s/srp/%s", url.PathEscape(pmax.symmid), url.PathEscape(srp)), nil, &capacity)
+	return capacity, err
+}
+
+// GetSRPCapacities Get capacity and efficiency of all storage resource pools
+func (pmax *PowerMax) GetSRPCapacities() ([]SRPCapacity, error) {
+	srps := struct {
+		SrpId []string `json:"srpId"`
+	}{}
+	err := pmax.Request("GET", fmt.Sprintf("/univmax/restapi/sloprovisioning/symmetrix/%s/srp", url.PathEscape(pmax.symmid)), nil, &srps)
+	if err != nil {
+		return nil, err
+	}
+
+	var capacities []SRPCapacity
+	for _, srp := range srps.SrpId {
+		capacity, err := pmax.GetSRPCapacity(srp)
+		if err != nil {
+			return nil, err
+		}
+		capacities = append(capacities, capacity)
+	}
+	return capacities, nil
+}
+
+// GetStorageGroupCapacity Get provisioned and used capacity of a storage group
+func (pmax *PowerMax) GetStorageGroupCapacity(sg string) (StorageGroupCapacity, error) {
+	var capacity StorageGroupCapacity
+	err := pmax.Request("GET", fmt.Sprintf("/univmax/restapi/sloprovisioning/symmetrix/%s/storagegroup/%s", url.PathEscape(pmax.symmid), url.PathEscape(sg)), nil, &capacity)
+	if err != nil {
+		return capacity, err
+	}
+	capacity.UsedGB = capacity.CapGB * (100 - capacity.VPSavedPercent) / 100
+	return capacity, nil
+}
+
+// GetArrayCapacity Get array level capacity and efficiency
+func (pmax *PowerMax) GetArrayCapacity() (ArrayCapacity, error) {
+	var capacity ArrayCapacity
+	err := pmax.Request("GET", fmt.Sprintf("/univmax/restapi/sloprovisioning/symmetrix/%s", url.PathEscape(pmax.symmid)), nil, &capacity)
+	return capacity, err
+}
@@ -0,0 +1,163 @@
+package powermax
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kckecheng/storagemetric/storage"
+)
+
+// Units of the metrics collected by Collector
+var metricUnits = map[string]string{
+	"HostIOs":           "IO/s",
+	"HostReads":         "IO/s",
+	"HostWrites":        "IO/s",
+	"HostMBReads":       "MB/s",
+	"HostMBWritten":     "MB/s",
+	"FEReadReqs":        "IO/s",
+	"FEWriteReqs":       "IO/s",
+	"ReadResponseTime":  "ms",
+	"WriteResponseTime": "ms",
+	"ResponseTime":      "ms",
+	"FEUtilization":     "%",
+	"AvgIOSize":         "KB",
+	"AvgReadSize":       "KB",
+	"AvgWriteSize":      "KB",
+}
+
+// Collector Adapter exposing PowerMax as a storage.Collector
+type Collector struct {
+	server   string
+	port     string
+	username string
+	password string
+	symmid   string
+	opts     []Option
+	pmax     *PowerMax
+}
+
+// NewCollector Init a PowerMax collector, call Connect before using it
+func NewCollector(server string, port string, username string, password string, symmid string) *Collector {
+	return &Collector{
+		server:   server,
+		port:     port,
+		username: username,
+		password: password,
+		symmid:   symmid,
+	}
+}
+
+// WithOptions Set options used to connect Unisphere on Connect
+func (c *Collector) WithOptions(opts ...Option) *Collector {
+	c.opts = opts
+	return c
+}
+
+// Connect Login PowerMax Unisphere
+func (c *Collector) Connect() error {
+	pmax, err := New(c.server, c.port, c.username, c.password, c.symmid, c.opts...)
+	if err != nil {
+		return err
+	}
+	c.pmax = pmax
+	return nil
+}
+
+// Resources List the array and its storage groups
+func (c *Collector) Resources() ([]storage.Resource, error) {
+	if c.pmax == nil {
+		return nil, errors.New("PowerMax collector is not connected")
+	}
+	return c.resources(c.pmax)
+}
+
+// List resources through the provided object, which may be bound to a context
+func (c *Collector) resources(pmax *PowerMax) ([]storage.Resource, error) {
+	sgs, err := pmax.GetStorageGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	resources := []storage.Resource{{Kind: storage.KindArray, ID: c.symmid, Name: c.symmid}}
+	for _, sg := range sgs {
+		resources = append(resources, storage.Resource{Kind: storage.KindStorageGroup, ID: sg, Name: sg})
+	}
+	return resources, nil
+}
+
+// Collect Collect array and storage group samples
+func (c *Collector) Collect(from time.Time, to time.Time) ([]storage.Sample, error) {
+	return c.CollectContext(context.Background(), from, to)
+}
+
+// CollectContext Collect array and storage group samples, abort once ctx is done
+func (c *Collector) CollectContext(ctx context.Context, from time.Time, to time.Time) ([]storage.Sample, error) {
+	if c.pmax == nil {
+		return nil, errors.New("PowerMax collector is not connected")
+	}
+	pmax := c.pmax.WithContext(ctx)
+
+	resources, err := c.resources(pmax)
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []storage.Sample
+	for _, res := range resources {
+		switch res.Kind {
+		case storage.KindArray:
+			metrics, err := pmax.GetArrayMetricSeries(from, to)
+			if err != nil && !errors.Is(err, ErrNoData) {
+				return nil, err
+			}
+			for _, metric := range metrics {
+				samples = append(samples, c.samples(res, metric.Timestamp, metric.values())...)
+			}
+		case storage.KindStorageGroup:
+			metrics, err := pmax.GetStorageGroupMetricSeries(res.ID, from, to)
+			if err != nil && !errors.Is(err, ErrNoData) {
+				return nil, err
+			}
+			for _, metric := range metrics {
+				samples = append(samples, c.samples(res, metric.Timestamp, metric.values())...)
+			}
+		}
+	}
+	return samples, nil
+}
+
+// Close Release the PowerMax object
+func (c *Collector) Close() error {
+	if c.pmax == nil {
+		return nil
+	}
+	err := c.pmax.Destroy()
+	c.pmax = nil
+	return err
+}
+
+// Convert metric values of a resource into samples, a zero timestamp means no data is available
+func (c *Collector) samples(res storage.Resource, ts int64, values map[string]float64) []storage.Sample {
+	var samples []storage.Sample
+	if ts == 0 {
+		return samples
+	}
+
+	for name, value := range values {
+		labels := map[string]string{"array": c.symmid}
+		if res.Kind == storage.KindStorageGroup {
+			labels["storage_group"] = res.ID
+		}
+		samples = append(samples, storage.Sample{
+			Kind:      res.Kind,
+			ID:        res.ID,
+			Metric:    name,
+			Unit:      metricUnits[name],
+			Timestamp: timestampToDate(ts),
+			Value:     value,
+			Labels:    labels,
+		})
+	}
+	return samples
+}
@@ -0,0 +1,82 @@
+package powermax
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/kckecheng/storagemetric/utils"
+)
+
+// Error categories, use errors.Is to check the category of an error returned by PowerMax methods
+var (
+	ErrAuth       = errors.New("authentication failed")
+	ErrNotFound   = errors.New("resource not found")
+	ErrBadRequest = errors.New("bad request")
+	ErrServer     = errors.New("server error")
+	ErrDecode     = errors.New("fail to decode response")
+	ErrNoData     = errors.New("no data in the time window")
+)
+
+// RequestError Failed Unisphere request with the HTTP status and the error message returned by Unisphere
+type RequestError struct {
+	Method     string
+	URI        string
+	StatusCode int
+	Message    string
+	Err        error
+}
+
+func (e *RequestError) Error() string {
+	message := fmt.Sprintf("%s %s: %s (status code %d)", e.Method, e.URI, e.Err.Error(), e.StatusCode)
+	if e.Message != "" {
+		message += ": " + e.Message
+	}
+	return message
+}
+
+// Unwrap Return the error category
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// Build an error from a response with a non 2xx status code
+func newRequestError(method string, URI string, resp *http.Response) *RequestError {
+	var category error
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		category = ErrAuth
+	case resp.StatusCode == http.StatusNotFound:
+		category = ErrNotFound
+	case resp.StatusCode >= 500:
+		category = ErrServer
+	default:
+		category = ErrBadRequest
+	}
+
+	// Unisphere reports errors as {"message": "..."}, fall back to the raw body otherwise
+	defer resp.Body.Close()
+	var message string
+	body, err := ioutil.ReadAll(resp.Body)
+	if err == nil {
+		ret := struct {
+			Message string `json:"message"`
+		}{}
+		if json.Unmarshal(body, &ret) == nil && ret.Message != "" {
+			message = ret.Message
+		} else {
+			message = strings.TrimSpace(string(body))
+		}
+	}
+
+	return &RequestError{
+		Method:     method,
+		URI:        URI,
+		StatusCode: resp.StatusCode,
+		Message:    utils.Redact(message),
+		Err:        category,
+	}
+}
@@ -0,0 +1,171 @@
+package powermax
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// HostMetric Metric of a host or an initiator
+type HostMetric struct {
+	HostIOs           float64 `json:"HostIOs"`
+	HostMBs           float64 `json:"HostMBs"`
+	Reads             float64 `json:"Reads"`
+	Writes            float64 `json:"Writes"`
+	MBRead            float64 `json:"MBRead"`
+	MBWritten         float64 `json:"MBWritten"`
+	ResponseTime      float64 `json:"ResponseTime"`
+	ReadResponseTime  float64 `json:"ReadResponseTime"`
+	WriteResponseTime float64 `json:"WriteResponseTime"`
+	Timestamp         int64   `json:"timestamp"`
+}
+
+// InitiatorMetric Metric of an initiator, which has the same metrics as a host
+type InitiatorMetric = HostMetric
+
+type PortGroupMetric struct {
+	PercentBusy float64 `json:"PercentBusy"`
+	IOs         float64 `json:"IOs"`
+	MBs         float64 `json:"MBs"`
+	Reads       float64 `json:"Reads"`
+	Writes      float64 `json:"Writes"`
+	MBRead      float64 `json:"MBRead"`
+	MBWritten   float64 `json:"MBWritten"`
+	AvgIOSize   float64 `json:"AvgIOSize"`
+	Timestamp   int64   `json:"timestamp"`
+}
+
+// Metrics shared by hosts and initiators
+var hostMetrics = []string{"HostIOs", "HostMBs", "Reads", "Writes", "MBRead", "MBWritten", "ResponseTime", "ReadResponseTime", "WriteResponseTime"}
+
+var (
+	hostQuery      = objectQuery{"Host", "hostId", hostMetrics}
+	initiatorQuery = objectQuery{"Initiator", "initiatorId", hostMetrics}
+	portGroupQuery = objectQuery{"PortGroup", "portGroupId", []string{"PercentBusy", "IOs", "MBs", "Reads", "Writes", "MBRead", "MBWritten", "AvgIOSize"}}
+)
+
+// HostTopology Initiators, port groups and storage groups a host is linked to through its masking views
+type HostTopology struct {
+	HostId        string   `json:"hostId"`
+	Initiators    []string `json:"initiators"`
+	MaskingViews  []string `json:"maskingViews"`
+	PortGroups    []string `json:"portGroups"`
+	StorageGroups []string `json:"storageGroups"`
+}
+
+// GetHosts List available hosts
+func (pmax *PowerMax) GetHosts() ([]string, error) {
+	return pmax.queryKeys("Host", nil, "hostInfo", "hostId")
+}
+
+// GetInitiators List available initiators
+func (pmax *PowerMax) GetInitiators() ([]string, error) {
+	return pmax.queryKeys("Initiator", nil, "initiatorInfo", "initiatorId")
+}
+
+// GetPortGroups List available port groups
+func (pmax *PowerMax) GetPortGroups() ([]string, error) {
+	return pmax.queryKeys("PortGroup", nil, "portGroupInfo", "portGroupId")
+}
+
+// GetHostTopology Get initiators, port groups and storage groups of a host
+func (pmax *PowerMax) GetHostTopology(host string) (HostTopology, error) {
+	topology := HostTopology{HostId: host}
+
+	hostInfo := struct {
+		HostId      string   `json:"hostId"`
+		Initiator   []string `json:"initiator"`
+		MaskingView []string `json:"maskingview"`
+	}{}
+	err := pmax.Request("GET", fmt.Sprintf("/univmax/restapi/sloprovisioning/symmetrix/%s/host/%s", url.PathEscape(pmax.symmid), url.PathEscape(host)), nil, &hostInfo)
+	if err != nil {
+		return topology, err
+	}
+	topology.Initiators = hostInfo.Initiator
+	topology.MaskingViews = hostInfo.MaskingView
+
+	// A port group or storage group may be shared by several masking views of the same host
+	seen := map[string]bool{}
+	for _, mv := range hostInfo.MaskingView {
+		mvInfo := struct {
+			MaskingViewId  string `json:"maskingViewId"`
+			PortGroupId    string `json:"portGroupId"`
+			StorageGroupId string `json:"storageGroupId"`
+		}{}
+		err := pmax.Request("GET", fmt.Sprintf("/univmax/restapi/sloprovisioning/symmetrix/%s/maskingview/%s", url.PathEscape(pmax.symmid), url.PathEscape(mv)), nil, &mvInfo)
+		if err != nil {
+			return topology, err
+		}
+		if mvInfo.PortGroupId != "" && !seen["pg:"+mvInfo.PortGroupId] {
+			seen["pg:"+mvInfo.PortGroupId] = true
+			topology.PortGroups = append(topology.PortGroups, mvInfo.PortGroupId)
+		}
+		if mvInfo.StorageGroupId != "" && !seen["sg:"+mvInfo.StorageGroupId] {
+			seen["sg:"+mvInfo.StorageGroupId] = true
+			topology.StorageGroups = append(topology.StorageGroups, mvInfo.StorageGroupId)
+		}
+	}
+	return topology, nil
+}
+
+// GetHostTopologies Get the topology of all available hosts
+func (pmax *PowerMax) GetHostTopologies() ([]HostTopology, error) {
+	hosts, err := pmax.GetHosts()
+	if err != nil {
+		return nil, err
+	}
+
+	var topologies []HostTopology
+	for _, host := range hosts {
+		topology, err := pmax.GetHostTopology(host)
+		if err != nil {
+			return nil, err
+		}
+		topologies = append(topologies, topology)
+	}
+	return topologies, nil
+}
+
+// GetHostMetricSeries Get all metric samples of a host within the time range ordered by timestamp,
+// ErrNoData is returned if there is no sample
+func (pmax *PowerMax) GetHostMetricSeries(host string, from time.Time, to time.Time) ([]HostMetric, error) {
+	var metrics []HostMetric
+	err := pmax.queryObject(hostQuery.with(host, from, to), &metrics, nil)
+	return metrics, err
+}
+
+// GetHostMetric Get the average metric of a host within the time range with the latest timestamp,
+// ErrNoData is returned if there is no sample
+func (pmax *PowerMax) GetHostMetric(host string, from time.Time, to time.Time) (HostMetric, error) {
+	var metric HostMetric
+	err := pmax.queryObject(hostQuery.with(host, from, to), &[]HostMetric{}, &metric)
+	return metric, err
+}
+
+// GetInitiatorMetricSeries Same as GetHostMetricSeries for an initiator
+func (pmax *PowerMax) GetInitiatorMetricSeries(initiator string, from time.Time, to time.Time) ([]HostMetric, error) {
+	var metrics []HostMetric
+	err := pmax.queryObject(initiatorQuery.with(initiator, from, to), &metrics, nil)
+	return metrics, err
+}
+
+// GetInitiatorMetric Same as GetHostMetric for an initiator
+func (pmax *PowerMax) GetInitiatorMetric(initiator string, from time.Time, to time.Time) (HostMetric, error) {
+	var metric HostMetric
+	err := pmax.queryObject(initiatorQuery.with(initiator, from, to), &[]HostMetric{}, &metric)
+	return metric, err
+}
+
+// GetPortGroupMetricSeries Same as GetHostMetricSeries for a port group
+func (pmax *PowerMax) GetPortGroupMetricSeries(pg string, from time.Time, to time.Time) ([]PortGroupMetric, error) {
+	var metrics []PortGroupMetric
+	err := pmax.queryObject(portGroupQuery.with(pg, from, to), &metrics, nil)
+	return metrics, err
+}
+
+// GetPortGroupMetric Same as GetHostMetric for a port group
+func (pmax *PowerMax) GetPortGroupMetric(pg string, from time.Time, to time.Time) (PortGroupMetric, error) {
+	var metric PortGroupMetric
+	err := pmax.queryObject(portGroupQuery.with(pg, from, to), &[]PortGroupMetric{}, &metric)
+	return metric, err
+}
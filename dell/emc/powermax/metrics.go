@@ -2,8 +2,8 @@ package powermax
 
 import (
 	"time"
-	// "fmt"
-	// "github.com/kckecheng/storagemetric/utils"
+
+	"github.com/kckecheng/storagemetric/utils"
 )
 
 type StorageGroupMetric struct {
@@ -34,172 +34,183 @@ type ArrayMetric struct {
 	Timestamp         int64   `json:"timestamp"`
 }
 
-// GetFEDirectors List available FE directors
-func (pmax *PowerMax) GetFEDirectors() []string {
-	var dirs []string
-
-	payload := struct {
-		SymmetrixId string `json:"symmetrixId"`
-	}{pmax.symmid}
-
-	fedirs := struct {
-		FEDirectorInfo []struct {
-			DirectorId         string `json:"directorId"`
-			FirstAvailableDate int64  `json:"firstAvailableDate"`
-			LastAvailableDate  int64  `json:"lastAvailableDate"`
-		} `json:"feDirectorInfo"`
-	}{}
-	pmax.Request("POST", "/univmax/restapi/performance/FEDirector/keys", payload, &fedirs)
-	for _, dir := range fedirs.FEDirectorInfo {
-		dirs = append(dirs, dir.DirectorId)
-	}
-
-	return dirs
+type FEDirectorMetric struct {
+	PercentBusy           float64 `json:"PercentBusy"`
+	HostIOs               float64 `json:"HostIOs"`
+	HostMBs               float64 `json:"HostMBs"`
+	ReadReqs              float64 `json:"ReadReqs"`
+	WriteReqs             float64 `json:"WriteReqs"`
+	ReadResponseTime      float64 `json:"ReadResponseTime"`
+	WriteResponseTime     float64 `json:"WriteResponseTime"`
+	QueueDepthUtilization float64 `json:"QueueDepthUtilization"`
+	Timestamp             int64   `json:"timestamp"`
 }
 
-func (pmax *PowerMax) GetDirPorts(dir string) []string {
-	var ports []string
+type FEPortMetric struct {
+	PercentBusy       float64 `json:"PercentBusy"`
+	IOs               float64 `json:"IOs"`
+	MBs               float64 `json:"MBs"`
+	Reads             float64 `json:"Reads"`
+	Writes            float64 `json:"Writes"`
+	MBRead            float64 `json:"MBRead"`
+	MBWritten         float64 `json:"MBWritten"`
+	ResponseTime      float64 `json:"ResponseTime"`
+	ReadResponseTime  float64 `json:"ReadResponseTime"`
+	WriteResponseTime float64 `json:"WriteResponseTime"`
+	Timestamp         int64   `json:"timestamp"`
+}
 
-	payload := struct {
-		SymmetrixId string `json:"symmetrixId"`
-		DirectorId  string `json:"directorId"`
-	}{pmax.symmid, dir}
+func (m StorageGroupMetric) values() map[string]float64 {
+	return map[string]float64{
+		"HostReads":         m.HostReads,
+		"HostWrites":        m.HostWrites,
+		"HostMBReads":       m.HostMBReads,
+		"HostMBWritten":     m.HostMBWritten,
+		"ReadResponseTime":  m.ReadResponseTime,
+		"WriteResponseTime": m.WriteResponseTime,
+		"ResponseTime":      m.ResponseTime,
+		"AvgIOSize":         m.AvgIOSize,
+		"AvgReadSize":       m.AvgReadSize,
+		"AvgWriteSize":      m.AvgWriteSize,
+	}
+}
 
-	dirports := struct {
-		FePortInfo []struct {
-			PortId             string `json:"portId"`
-			FirstAvailableDate string `json:"firstAvailableDate"`
-			LastAvailableDate  string `json:"lastAvailableDate"`
-		} `json:"fePortInfo"`
-	}{}
-	pmax.Request("POST", "/univmax/restapi/performance/FEPort/keys", payload, &dirports)
-	for _, port := range dirports.FePortInfo {
-		ports = append(ports, port.PortId)
+func (m ArrayMetric) values() map[string]float64 {
+	return map[string]float64{
+		"HostIOs":           m.HostIOs,
+		"HostReads":         m.HostReads,
+		"HostWrites":        m.HostWrites,
+		"HostMBReads":       m.HostMBReads,
+		"HostMBWritten":     m.HostMBWritten,
+		"FEReadReqs":        m.FEReadReqs,
+		"FEWriteReqs":       m.FEWriteReqs,
+		"ReadResponseTime":  m.ReadResponseTime,
+		"WriteResponseTime": m.WriteResponseTime,
+		"FEUtilization":     m.FEUtilization,
 	}
+}
+
+// GetFEDirectors List available FE directors
+func (pmax *PowerMax) GetFEDirectors() ([]string, error) {
+	return pmax.queryKeys("FEDirector", nil, "feDirectorInfo", "directorId")
+}
 
-	return ports
+// GetDirPorts List available ports of a FE director
+func (pmax *PowerMax) GetDirPorts(dir string) ([]string, error) {
+	return pmax.queryKeys("FEPort", map[string]string{"directorId": dir}, "fePortInfo", "portId")
 }
 
-func (pmax *PowerMax) GetStorageGroups() []string {
-	var sgs []string
+// GetStorageGroups List available storage groups
+func (pmax *PowerMax) GetStorageGroups() ([]string, error) {
+	return pmax.queryKeys("StorageGroup", nil, "storageGroupInfo", "storageGroupId")
+}
 
-	payload := struct {
-		SymmetrixId string `json:"symmetrixId"`
-	}{pmax.symmid}
+// GetStorageGroupMetricSeries Get all metric samples of a storage group within the time range ordered by timestamp,
+// ErrNoData is returned if there is no sample
+func (pmax *PowerMax) GetStorageGroupMetricSeries(sg string, from time.Time, to time.Time) ([]StorageGroupMetric, error) {
+	var metrics []StorageGroupMetric
+	query := MetricQuery{
+		Category:   "StorageGroup",
+		Keys:       map[string]string{"storageGroupId": sg},
+		Metrics:    []string{"HostReads", "HostWrites", "HostMBReads", "HostMBWritten", "ResponseTime", "ReadResponseTime", "WriteResponseTime", "AvgIOSize", "AvgReadSize", "AvgWriteSize"},
+		DataFormat: pmax.dataFormat,
+		From:       from,
+		To:         to,
+	}
+	err := pmax.queryMetrics(query, &metrics)
+	return metrics, err
+}
 
-	sgroups := struct {
-		StorageGroupInfo []struct {
-			StorageGroupId     string `json:"storageGroupId"`
-			FirstAvailableDate int64  `json:"firstAvailableDate"`
-			LastAvailableDate  int64  `json:"lastAvailableDate"`
-		} `json:"storageGroupInfo"`
-	}{}
-	pmax.Request("POST", "/univmax/restapi/performance/StorageGroup/keys", payload, &sgroups)
-	for _, sg := range sgroups.StorageGroupInfo {
-		sgs = append(sgs, sg.StorageGroupId)
+// GetStorageGroupMetric Get the latest metric of a storage group within the time range, ErrNoData is returned if there is no sample
+func (pmax *PowerMax) GetStorageGroupMetric(sg string, from time.Time, to time.Time) (StorageGroupMetric, error) {
+	var metric StorageGroupMetric
+	metrics, err := pmax.GetStorageGroupMetricSeries(sg, from, to)
+	if err != nil {
+		return metric, err
 	}
+	err = utils.Aggregate(metrics, utils.AggLast, &metric)
+	return metric, err
+}
 
-	return sgs
-}
-
-func (pmax *PowerMax) GetStorageGroupMetric(sg string, from time.Time, to time.Time) StorageGroupMetric {
-	payload := struct {
-		SymmetrixId    string   `json:"symmetrixId"`
-		StorageGroupId string   `json:"storageGroupId"`
-		DataFormat     string   `json:"dataFormat"`
-		StartDate      int64    `json:"startDate"`
-		EndDate        int64    `json:"endDate"`
-		Metrics        []string `json:"metrics"`
-	}{
-		pmax.symmid,
-		sg,
-		"Average",
-		dateToTimestamp(from),
-		dateToTimestamp(to),
-		[]string{"HostReads", "HostWrites", "HostMBReads", "HostMBWritten", "ResponseTime", "ReadResponseTime", "WriteResponseTime", "AvgIOSize", "AvgReadSize", "AvgWriteSize"},
+// GetArrayMetricSeries Get all array metric samples within the time range ordered by timestamp,
+// ErrNoData is returned if there is no sample
+func (pmax *PowerMax) GetArrayMetricSeries(from time.Time, to time.Time) ([]ArrayMetric, error) {
+	var metrics []ArrayMetric
+	query := MetricQuery{
+		Category:   "Array",
+		Metrics:    []string{"HostIOs", "HostReads", "HostWrites", "HostMBReads", "HostMBWritten", "FEReadReqs", "FEWriteReqs", "ReadResponseTime", "WriteResponseTime", "FEUtilization"},
+		DataFormat: pmax.dataFormat,
+		From:       from,
+		To:         to,
 	}
-	result := struct {
-		ResultList struct {
-			Result []StorageGroupMetric `json:"result"`
-			From   int64                `json:"from"`
-			To     int64                `json:"to"`
-		} `json:"resultList"`
-		Id             string `json:"id"`
-		Count          int64  `json:"count"`
-		ExpirationTime int64  `json:"expirationTime"`
-		MaxPageSize    int64  `json:"maxPageSize"`
-		WarningMessage string `json:"warningMessage"`
-	}{}
-	pmax.Request("POST", "/univmax/restapi/performance/StorageGroup/metrics", payload, &result)
-
-	metrics := result.ResultList.Result
-	// Only return the latest result if exists
-	if len(metrics) == 0 {
-		return StorageGroupMetric{}
+	err := pmax.queryMetrics(query, &metrics)
+	return metrics, err
+}
+
+// GetArrayMetric Get the average array metric within the time range with the latest timestamp,
+// ErrNoData is returned if there is no sample
+func (pmax *PowerMax) GetArrayMetric(from time.Time, to time.Time) (ArrayMetric, error) {
+	var metric ArrayMetric
+	metrics, err := pmax.GetArrayMetricSeries(from, to)
+	if err != nil {
+		return metric, err
 	}
-	return metrics[len(metrics)-1]
-}
-
-func (pmax *PowerMax) GetArrayMetric(from time.Time, to time.Time) ArrayMetric {
-	payload := struct {
-		SymmetrixId string   `json:"symmetrixId"`
-		DataFormat  string   `json:"dataFormat"`
-		StartDate   int64    `json:"startDate"`
-		EndDate     int64    `json:"endDate"`
-		Metrics     []string `json:"metrics"`
-	}{
-		pmax.symmid,
-		"Average",
-		dateToTimestamp(from),
-		dateToTimestamp(to),
-		[]string{"HostIOs", "HostReads", "HostWrites", "HostMBReads", "HostMBWritten", "FEReadReqs", "FEWriteReqs", "ReadResponseTime", "WriteResponseTime", "FEUtilization"},
+	err = utils.Aggregate(metrics, utils.AggAvg, &metric)
+	return metric, err
+}
+
+// GetFEDirectorMetricSeries Get all metric samples of a FE director within the time range ordered by timestamp,
+// ErrNoData is returned if there is no sample
+func (pmax *PowerMax) GetFEDirectorMetricSeries(dir string, from time.Time, to time.Time) ([]FEDirectorMetric, error) {
+	var metrics []FEDirectorMetric
+	query := MetricQuery{
+		Category:   "FEDirector",
+		Keys:       map[string]string{"directorId": dir},
+		Metrics:    []string{"PercentBusy", "HostIOs", "HostMBs", "ReadReqs", "WriteReqs", "ReadResponseTime", "WriteResponseTime", "QueueDepthUtilization"},
+		DataFormat: pmax.dataFormat,
+		From:       from,
+		To:         to,
 	}
-	result := struct {
-		ResultList struct {
-			Result []ArrayMetric `json:"result"`
-			From   int64         `json:"from"`
-			To     int64         `json:"to"`
-		} `json:"resultList"`
-		Id             string `json:"id"`
-		Count          int64  `json:"count"`
-		ExpirationTime int64  `json:"expirationTime"`
-		MaxPageSize    int64  `json:"maxPageSize"`
-		WarningMessage string `json:"warningMessage"`
-	}{}
-	pmax.Request("POST", "/univmax/restapi/performance/Array/metrics", payload, &result)
-
-	metrics := result.ResultList.Result
-
-	// Calculate an average as return
-	var avgMetric ArrayMetric
-	mn := len(metrics)
-	for _, metric := range metrics {
-		avgMetric.HostIOs += metric.HostIOs
-		avgMetric.HostReads += metric.HostReads
-		avgMetric.HostWrites += metric.HostWrites
-		avgMetric.HostMBReads += metric.HostMBReads
-		avgMetric.HostMBWritten += metric.HostMBWritten
-		avgMetric.FEReadReqs += metric.FEReadReqs
-		avgMetric.FEWriteReqs += metric.FEWriteReqs
-		avgMetric.ReadResponseTime += metric.ReadResponseTime
-		avgMetric.WriteResponseTime += metric.WriteResponseTime
-		avgMetric.FEUtilization += metric.FEUtilization
-		// Grab the latest timestamp if exists
-		if avgMetric.Timestamp < metric.Timestamp {
-			avgMetric.Timestamp += metric.Timestamp
-		}
+	err := pmax.queryMetrics(query, &metrics)
+	return metrics, err
+}
+
+// GetFEDirectorMetric Get the average metric of a FE director within the time range with the latest timestamp,
+// ErrNoData is returned if there is no sample
+func (pmax *PowerMax) GetFEDirectorMetric(dir string, from time.Time, to time.Time) (FEDirectorMetric, error) {
+	var metric FEDirectorMetric
+	metrics, err := pmax.GetFEDirectorMetricSeries(dir, from, to)
+	if err != nil {
+		return metric, err
 	}
-	if mn != 0 {
-		avgMetric.HostIOs = avgMetric.HostIOs / float64(mn)
-		avgMetric.HostReads = avgMetric.HostReads / float64(mn)
-		avgMetric.HostWrites = avgMetric.HostWrites / float64(mn)
-		avgMetric.HostMBReads = avgMetric.HostMBReads / float64(mn)
-		avgMetric.HostMBWritten = avgMetric.HostMBWritten / float64(mn)
-		avgMetric.FEReadReqs = avgMetric.FEReadReqs / float64(mn)
-		avgMetric.FEWriteReqs = avgMetric.FEWriteReqs / float64(mn)
-		avgMetric.ReadResponseTime = avgMetric.ReadResponseTime / float64(mn)
-		avgMetric.WriteResponseTime = avgMetric.WriteResponseTime / float64(mn)
-		avgMetric.FEUtilization = avgMetric.FEUtilization / float64(mn)
+	err = utils.Aggregate(metrics, utils.AggAvg, &metric)
+	return metric, err
+}
+
+// GetFEPortMetricSeries Get all metric samples of a FE port within the time range ordered by timestamp,
+// ErrNoData is returned if there is no sample
+func (pmax *PowerMax) GetFEPortMetricSeries(dir string, port string, from time.Time, to time.Time) ([]FEPortMetric, error) {
+	var metrics []FEPortMetric
+	query := MetricQuery{
+		Category:   "FEPort",
+		Keys:       map[string]string{"directorId": dir, "portId": port},
+		Metrics:    []string{"PercentBusy", "IOs", "MBs", "Reads", "Writes", "MBRead", "MBWritten", "ResponseTime", "ReadResponseTime", "WriteResponseTime"},
+		DataFormat: pmax.dataFormat,
+		From:       from,
+		To:         to,
+	}
+	err := pmax.queryMetrics(query, &metrics)
+	return metrics, err
+}
+
+// GetFEPortMetric Get the average metric of a FE port within the time range with the latest timestamp,
+// ErrNoData is returned if there is no sample
+func (pmax *PowerMax) GetFEPortMetric(dir string, port string, from time.Time, to time.Time) (FEPortMetric, error) {
+	var metric FEPortMetric
+	metrics, err := pmax.GetFEPortMetricSeries(dir, port, from, to)
+	if err != nil {
+		return metric, err
 	}
-	return avgMetric
+	err = utils.Aggregate(metrics, utils.AggAvg, &metric)
+	return metric, err
 }
@@ -0,0 +1,63 @@
+package powermax
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"github.com/kckecheng/storagemetric/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// Option Optional setting applied when initializing the PowerMax object
+type Option func(*utils.ClientOptions)
+
+func newOptions(opts []Option) utils.ClientOptions {
+	var o utils.ClientOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithTLS Set how the server certificate is verified and the client certificate to present,
+// the server certificate is verified against the system CA pool by default
+func WithTLS(tlsOpts utils.TLSOptions) Option {
+	return func(o *utils.ClientOptions) { o.TLS = tlsOpts }
+}
+
+// WithInsecure Skip the verification of the server certificate
+func WithInsecure() Option {
+	return func(o *utils.ClientOptions) { o.TLS.Insecure = true }
+}
+
+// WithTLSConfig Use the TLS configuration as is, WithTLS and WithInsecure are ignored
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *utils.ClientOptions) { o.TLSConfig = config }
+}
+
+// WithHTTPClient Use a copy of the client, the TLS options are ignored since the transport is taken as is
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *utils.ClientOptions) { o.Client = client }
+}
+
+// WithTimeout Set the default timeout of each request, 0 means no timeout
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *utils.ClientOptions) { o.Timeout = &timeout }
+}
+
+// WithLogger Use the logger as the process wide logger shared by all objects, it is ignored if another object
+// has set its logger already
+func WithLogger(logger *log.Logger) Option {
+	return func(o *utils.ClientOptions) { o.Logger = logger }
+}
+
+// WithPort Port of Unisphere, it takes precedence over the port passed to New and NewWithContext
+func WithPort(port string) Option {
+	return func(o *utils.ClientOptions) { o.Port = port }
+}
+
+// WithRetry Set how failed requests are retried, each request is sent only once by default
+func WithRetry(policy utils.RetryPolicy) Option {
+	return func(o *utils.ClientOptions) { o.Retry = policy }
+}
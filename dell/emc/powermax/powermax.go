@@ -1,9 +1,11 @@
 package powermax
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/kckecheng/storagemetric/utils"
@@ -11,12 +13,15 @@ import (
 
 // PowerMax PowerMax array object
 type PowerMax struct {
-	server   string
-	port     string
-	username string
-	password string
-	symmid   string
-	client   http.Client
+	server     string
+	port       string
+	username   string
+	password   string
+	symmid     string
+	client     http.Client
+	ctx        context.Context
+	retry      utils.RetryPolicy
+	dataFormat string
 }
 
 // Covert UTC timestamp(millisecond) to date
@@ -46,36 +51,60 @@ func populateCommonHeaders(req *http.Request) {
 }
 
 // New Init PowerMax Object
-func New(server string, port string, username string, password string, symmid string) (*PowerMax, error) {
-	if utils.Logger == nil {
-		utils.InitLogger("", "")
+func New(server string, port string, username string, password string, symmid string, opts ...Option) (*PowerMax, error) {
+	return NewWithContext(context.Background(), server, port, username, password, symmid, opts...)
+}
+
+// NewWithContext Init PowerMax Object, the validation request is aborted once ctx is done,
+// the port specified by WithPort takes precedence over port
+func NewWithContext(ctx context.Context, server string, port string, username string, password string, symmid string, opts ...Option) (*PowerMax, error) {
+	o := newOptions(opts)
+	o.InitLogger()
+	if o.Port != "" {
+		port = o.Port
 	}
 
 	var err error
-	utils.Log("debug", fmt.Sprintf("server: %s, username: %s, password: %s, port: %s, symmid: %s", server, username, password, port, symmid))
+	utils.Log("debug", fmt.Sprintf("server: %s, username: %s, port: %s, symmid: %s", server, username, port, symmid))
 	if utils.EmptyStrExists(server, username, password, symmid) == true {
 		return nil, errors.New("PowerMax server address, username, password and symmid must be specified")
 	}
-
-	client := utils.InitHttpClient()
+	utils.RegisterSecret(password)
+	connected := false
+	defer func() {
+		if !connected {
+			utils.UnregisterSecret(password)
+		}
+	}()
+
+	client, err := o.HttpClient()
+	if err != nil {
+		return nil, err
+	}
 
 	// Check if the provided parameters are correct
-	req, err := utils.InitHttpRequest("GET", utils.URL("https", server, port, "/univmax/restapi/system/symmetrix", "/"+symmid), nil)
+	uri := "/univmax/restapi/system/symmetrix/" + symmid
+	req, err := utils.InitHttpRequestWithContext(ctx, "GET", utils.URL("https", server, port, uri), nil)
+	if err != nil {
+		return nil, err
+	}
 	req.SetBasicAuth(username, password)
 	populateCommonHeaders(req)
 
-	resp, err := utils.DoHttpRequest(&client, req)
+	resp, err := utils.DoHttpRequestWithRetry(&client, req, o.Retry)
 	if err != nil {
 		utils.Log("error", err.Error())
 		return nil, err
 	}
 
 	if resp.StatusCode != 200 {
-		message := fmt.Sprintf("Fail to query symmtric with symmid %s", symmid)
-		utils.Log("error", message)
-		return nil, errors.New(message)
+		err := newRequestError("GET", uri, resp)
+		utils.Log("error", fmt.Sprintf("Fail to query symmtric with symmid %s: %s", symmid, err.Error()))
+		return nil, err
 	}
+	resp.Body.Close()
 
+	connected = true
 	return &PowerMax{
 		server:   server,
 		username: username,
@@ -83,11 +112,52 @@ func New(server string, port string, username string, password string, symmid st
 		port:     port,
 		symmid:   symmid,
 		client:   client,
+		retry:    o.Retry,
 	}, nil
 }
 
+// Destroy Release the object, there is no session to log out since each request is authenticated separately
+func (pmax *PowerMax) Destroy() error {
+	utils.UnregisterSecret(pmax.password)
+	return nil
+}
+
+// WithContext Get a copy of the object whose requests are all aborted once ctx is done
+func (pmax *PowerMax) WithContext(ctx context.Context) *PowerMax {
+	clone := *pmax
+	clone.ctx = ctx
+	return &clone
+}
+
+// WithDataFormat Get a copy of the object whose typed metric methods query samples in the data format,
+// DataFormatAverage or DataFormatMaximum
+func (pmax *PowerMax) WithDataFormat(format string) *PowerMax {
+	clone := *pmax
+	clone.dataFormat = format
+	return &clone
+}
+
+// SetTimeout Set the default timeout of each request, 0 means no timeout. It is not safe to call while
+// requests are being sent from other goroutines, set it right after initialization or use WithTimeout
+func (pmax *PowerMax) SetTimeout(timeout time.Duration) {
+	pmax.client.Timeout = timeout
+}
+
+// Context bound to the object, background context if none is bound
+func (pmax *PowerMax) context() context.Context {
+	if pmax.ctx == nil {
+		return context.Background()
+	}
+	return pmax.ctx
+}
+
 // Request Send get/post/delete request
 func (pmax *PowerMax) Request(method string, URI string, payload interface{}, result interface{}) error {
+	return pmax.RequestWithContext(pmax.context(), method, URI, payload, result)
+}
+
+// RequestWithContext Send get/post/delete request which is aborted once ctx is done
+func (pmax *PowerMax) RequestWithContext(ctx context.Context, method string, URI string, payload interface{}, result interface{}) error {
 	requestParams := fmt.Sprintf("method: %s, URI: %s, payload: %#v", method, URI, payload)
 	utils.Log("debug", requestParams)
 	if utils.EmptyStrExists(method, URI) == true {
@@ -95,20 +165,38 @@ func (pmax *PowerMax) Request(method string, URI string, payload interface{}, re
 	}
 
 	url := utils.URL("https", pmax.server, pmax.port, URI)
-	req, err := utils.InitHttpRequest(method, url, payload)
+	req, err := utils.InitHttpRequestWithContext(ctx, method, url, payload)
+	if err != nil {
+		return err
+	}
 
 	req.SetBasicAuth(pmax.username, pmax.password)
 	populateCommonHeaders(req)
+	// Performance queries are read only even though they are sent as POST
+	if method == "POST" && strings.HasPrefix(URI, "/univmax/restapi/performance/") {
+		utils.MarkIdempotent(req)
+	}
 
-	resp, err := utils.DoHttpRequest(&pmax.client, req)
+	resp, err := utils.DoHttpRequestWithRetry(&pmax.client, req, pmax.retry)
 	if err != nil {
 		utils.Log("error", err.Error())
 		return err
 	}
 
 	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		// Do not need to capture the response
+		if result == nil {
+			resp.Body.Close()
+			return nil
+		}
 		err := utils.GetHttpResponseJson(resp, result)
-		return err
+		if err != nil {
+			return &RequestError{Method: method, URI: URI, StatusCode: resp.StatusCode, Message: err.Error(), Err: ErrDecode}
+		}
+		return nil
 	}
-	return errors.New("Invalid status code")
+
+	reqErr := newRequestError(method, URI, resp)
+	utils.Log("error", reqErr.Error())
+	return reqErr
 }
@@ -1,10 +1,21 @@
 package powermax
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/kckecheng/storagemetric/storage"
+	"github.com/kckecheng/storagemetric/utils"
+	"github.com/sirupsen/logrus"
 )
 
 var server = flag.String("server", "", "PowerMax Unisphere IP/FQDN")
@@ -12,7 +23,9 @@ var port = flag.String("port", "8443", "PowerMax Unisphere port, 8443 as default
 var username = flag.String("username", "", "PowerMax user name")
 var password = flag.String("password", "", "PowerMax user password")
 var symmid = flag.String("symmid", "", "PowerMax symmetrix id")
-var interval = flag.Int("interval", 10, "Interval in seconds to collect metric, 10 as default")
+var interval = flag.Int("interval", 300, "Interval in seconds to collect metric, 300 as default and at least 300 since Unisphere keeps samples at 5 minute granularity")
+var insecure = flag.Bool("insecure", false, "Skip the verification of the server certificate")
+var cacert = flag.String("cacert", "", "CA bundle to verify the server certificate")
 
 func FailIfError(t *testing.T, err error) {
 	if err != nil {
@@ -28,11 +41,442 @@ func TestPowerMax(t *testing.T) {
 	}
 
 	var err error
-	pmax, err := New(*server, *port, *username, *password, *symmid)
+	pmax, err := New(*server, *port, *username, *password, *symmid, WithTLS(utils.TLSOptions{Insecure: *insecure, CAFile: *cacert}))
 	FailIfError(t, err)
 
+	window := time.Second * time.Duration(*interval)
+	if window < 5*time.Minute {
+		window = 5 * time.Minute
+	}
 	current_tm := time.Now()
-	from_tm := current_tm.Add(-time.Second * time.Duration(*interval))
-	arrmetric := pmax.GetArrayMetric(from_tm, current_tm)
+	from_tm := current_tm.Add(-window)
+	arrmetric, err := pmax.GetArrayMetric(from_tm, current_tm)
+	if errors.Is(err, ErrNoData) {
+		t.Skip("No array sample is available within the time window")
+	}
+	FailIfError(t, err)
 	t.Log(arrmetric)
+
+	dirs, err := pmax.GetFEDirectors()
+	FailIfError(t, err)
+	for _, dir := range dirs {
+		dirmetric, err := pmax.GetFEDirectorMetric(dir, from_tm, current_tm)
+		if errors.Is(err, ErrNoData) {
+			t.Log(dir, "has no sample within the time window")
+		} else {
+			FailIfError(t, err)
+			t.Log(dir, dirmetric)
+		}
+
+		ports, err := pmax.GetDirPorts(dir)
+		FailIfError(t, err)
+		for _, port := range ports {
+			portmetric, err := pmax.GetFEPortMetric(dir, port, from_tm, current_tm)
+			if errors.Is(err, ErrNoData) {
+				t.Log(dir, port, "has no sample within the time window")
+				continue
+			}
+			FailIfError(t, err)
+			t.Log(dir, port, portmetric)
+		}
+	}
+}
+
+// Connect a stand-in Unisphere serving symmetrix 000197900123 and requests handled by handler
+func fakeUnisphere(t *testing.T, handler http.HandlerFunc) (*PowerMax, func()) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/univmax/restapi/system/symmetrix/000197900123" {
+			w.Write([]byte(`{"symmetrixId": "000197900123"}`))
+			return
+		}
+		handler(w, r)
+	}))
+
+	addr := strings.Split(strings.TrimPrefix(ts.URL, "https://"), ":")
+	pmax, err := New(addr[0], addr[1], "smc", "smc", "000197900123", WithInsecure())
+	if err != nil {
+		ts.Close()
+		t.Fatal(err)
+	}
+	return pmax, ts.Close
+}
+
+func TestRequestError(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/univmax/restapi/system/symmetrix/000197900123":
+			w.Write([]byte(`{"symmetrixId": "000197900123"}`))
+		case "/univmax/restapi/performance/StorageGroup/keys":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"message": "Performance database is not available"}`))
+		case "/univmax/restapi/performance/Array/metrics":
+			w.Write([]byte(`{"resultList": {"result": []}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	addr := strings.Split(strings.TrimPrefix(ts.URL, "https://"), ":")
+	_, err := New(addr[0], addr[1], "smc", "smc", "000197900000", WithInsecure())
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	pmax, err := New(addr[0], addr[1], "smc", "smc", "000197900123", WithInsecure())
+	FailIfError(t, err)
+
+	_, err = pmax.GetStorageGroups()
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) || !errors.Is(err, ErrServer) || reqErr.StatusCode != 500 || reqErr.Message != "Performance database is not available" {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	_, err = pmax.GetArrayMetric(time.Now().Add(-time.Minute), time.Now())
+	if !errors.Is(err, ErrNoData) {
+		t.Errorf("Expected ErrNoData, got %v", err)
+	}
+}
+
+func TestGetMetrics(t *testing.T) {
+	pmax, done := fakeUnisphere(t, func(w http.ResponseWriter, r *http.Request) {
+		payload := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		if r.URL.Path != "/univmax/restapi/performance/StorageGroup/metrics" || payload["dataFormat"] != "Maximum" || payload["storageGroupId"] != "sg1" || payload["symmetrixId"] != "000197900123" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"resultList": {"result": [
+			{"PercentCacheWP": 2.5, "BEReqs": 300, "HostReads": null, "timestamp": 1600000600000},
+			{"PercentCacheWP": 1.5, "BEReqs": 200, "Unsupported": "N/A", "timestamp": 1600000300000}
+		]}}`))
+	})
+	defer done()
+
+	metrics, err := pmax.GetMetrics(MetricQuery{
+		Category:   "StorageGroup",
+		Keys:       map[string]string{"storageGroupId": "sg1"},
+		Metrics:    []string{"PercentCacheWP", "BEReqs"},
+		DataFormat: DataFormatMaximum,
+		From:       time.Now().Add(-time.Hour),
+		To:         time.Now(),
+	})
+	FailIfError(t, err)
+	if len(metrics) != 2 || metrics[0].Timestamp != 1600000300000 || metrics[0].Values["BEReqs"] != 200 || metrics[1].Values["PercentCacheWP"] != 2.5 {
+		t.Errorf("Unexpected metrics %+v", metrics)
+	}
+	if _, ok := metrics[0].Values["timestamp"]; ok {
+		t.Error("timestamp should not be part of values")
+	}
+	if len(metrics[0].Values) != 2 || len(metrics[1].Values) != 2 {
+		t.Errorf("Non-numeric values should be skipped %+v", metrics)
+	}
+
+	// Typed methods query the data format bound to the object
+	sgs, err := pmax.WithDataFormat(DataFormatMaximum).GetStorageGroupMetricSeries("sg1", time.Now().Add(-time.Hour), time.Now())
+	FailIfError(t, err)
+	if len(sgs) != 2 || sgs[0].Timestamp != 1600000300000 {
+		t.Errorf("Unexpected storage group metrics %+v", sgs)
+	}
+	if _, err := pmax.GetStorageGroupMetricSeries("sg1", time.Now().Add(-time.Hour), time.Now()); err == nil {
+		t.Error("Expect the average data format by default")
+	}
+}
+
+func TestBackendMetrics(t *testing.T) {
+	pmax, done := fakeUnisphere(t, func(w http.ResponseWriter, r *http.Request) {
+		payload := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		switch {
+		case r.URL.Path == "/univmax/restapi/performance/Disk/metrics" && payload["diskId"] == "1":
+		case r.URL.Path == "/univmax/restapi/performance/DiskGroup/metrics" && payload["diskGroupId"] == "0":
+		case r.URL.Path == "/univmax/restapi/performance/Initiator/metrics" && payload["initiatorId"] == "10000000c9a1b2c3":
+			w.Write([]byte(`{"resultList": {"result": [{"HostIOs": 50, "ResponseTime": 0.4, "timestamp": 1600000300000}]}}`))
+			return
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"resultList": {"result": [
+			{"PercentBusy": 30, "IOs": 100, "timestamp": 1600000600000},
+			{"PercentBusy": 10, "IOs": 300, "timestamp": 1600000300000}
+		]}}`))
+	})
+	defer done()
+
+	from, to := time.Now().Add(-time.Hour), time.Now()
+	series, err := pmax.GetDiskMetricSeries("1", from, to)
+	FailIfError(t, err)
+	if len(series) != 2 || series[0].Timestamp != 1600000300000 || series[0].IOs != 300 {
+		t.Errorf("Unexpected disk metrics %+v", series)
+	}
+	metric, err := pmax.GetDiskGroupMetric("0", from, to)
+	FailIfError(t, err)
+	if metric.PercentBusy != 20 || metric.IOs != 200 || metric.Timestamp != 1600000600000 {
+		t.Errorf("Unexpected disk group metric %+v", metric)
+	}
+	initiator, err := pmax.GetInitiatorMetric("10000000c9a1b2c3", from, to)
+	FailIfError(t, err)
+	if initiator.HostIOs != 50 || initiator.ResponseTime != 0.4 {
+		t.Errorf("Unexpected initiator metric %+v", initiator)
+	}
+}
+
+func TestGetKeys(t *testing.T) {
+	pmax, done := fakeUnisphere(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/univmax/restapi/performance/FEPort/keys":
+			payload := map[string]string{}
+			json.NewDecoder(r.Body).Decode(&payload)
+			if payload["directorId"] != "FA-1D" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"fePortInfo": [{"portId": "4", "firstAvailableDate": 1600000000000, "lastAvailableDate": 1600000300000}, {"portId": "28", "firstAvailableDate": 1600000000000, "lastAvailableDate": 1600000300000}]}`))
+		case "/univmax/restapi/performance/SRP/keys":
+			w.Write([]byte(`{"srpInfo": [{"srpId": "SRP_1", "firstAvailableDate": 1600000000000}]}`))
+		case "/univmax/restapi/performance/BEDirector/keys":
+			w.Write([]byte(`{"beDirectorInfo": [{"directorId": "DF-1C"}, {"directorId": "DF-2C"}]}`))
+		case "/univmax/restapi/performance/RDFA/keys":
+			w.Write([]byte(`{"rdfaInfo": [{"raGroupId": "10"}]}`))
+		case "/univmax/restapi/performance/RDFS/keys":
+			w.Write([]byte(`{"rdfsInfo": [{"raGroupId": "20"}, {"raGroupId": "21"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer done()
+
+	srps, err := pmax.GetSRPs()
+	FailIfError(t, err)
+	dirs, err := pmax.GetBEDirectors()
+	FailIfError(t, err)
+	if len(srps) != 1 || srps[0] != "SRP_1" || len(dirs) != 2 || dirs[1] != "DF-2C" {
+		t.Errorf("Unexpected keys %v %v", srps, dirs)
+	}
+	ports, err := pmax.GetDirPorts("FA-1D")
+	FailIfError(t, err)
+	if len(ports) != 2 || ports[0] != "4" || ports[1] != "28" {
+		t.Errorf("Unexpected FE ports %v", ports)
+	}
+	groups, err := pmax.GetRDFGroups()
+	FailIfError(t, err)
+	if len(groups) != 3 || groups[0] != (RDFGroup{Id: "10", Mode: RDFModeAsync}) || groups[2] != (RDFGroup{Id: "21", Mode: RDFModeSync}) {
+		t.Errorf("Unexpected RDF groups %+v", groups)
+	}
+	if _, err := pmax.GetDisks(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestGetHostTopology(t *testing.T) {
+	pmax, done := fakeUnisphere(t, func(w http.ResponseWriter, r *http.Request) {
+		prefix := "/univmax/restapi/sloprovisioning/symmetrix/000197900123"
+		if r.URL.EscapedPath() == prefix+"/host/db%2F02" {
+			w.Write([]byte(`{"hostId": "db/02", "initiator": ["10000000c9a1b2c5"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case prefix + "/host/db01":
+			w.Write([]byte(`{"hostId": "db01", "initiator": ["10000000c9a1b2c3", "10000000c9a1b2c4"], "maskingview": ["db01_data_mv", "db01_log_mv"]}`))
+		case prefix + "/maskingview/db01_data_mv":
+			w.Write([]byte(`{"maskingViewId": "db01_data_mv", "portGroupId": "db_pg", "storageGroupId": "db01_data_sg"}`))
+		case prefix + "/maskingview/db01_log_mv":
+			w.Write([]byte(`{"maskingViewId": "db01_log_mv", "portGroupId": "db_pg", "storageGroupId": "db01_log_sg"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer done()
+
+	topology, err := pmax.GetHostTopology("db01")
+	FailIfError(t, err)
+	if len(topology.Initiators) != 2 || len(topology.PortGroups) != 1 || topology.PortGroups[0] != "db_pg" || len(topology.StorageGroups) != 2 {
+		t.Errorf("Unexpected topology %+v", topology)
+	}
+
+	// Host IDs are escaped in the URL path
+	topology, err = pmax.GetHostTopology("db/02")
+	FailIfError(t, err)
+	if len(topology.Initiators) != 1 {
+		t.Errorf("Unexpected topology %+v", topology)
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	pmax, done := fakeUnisphere(t, func(w http.ResponseWriter, r *http.Request) {
+		prefix := "/univmax/restapi/sloprovisioning/symmetrix/000197900123"
+		switch r.URL.Path {
+		case prefix + "/srp":
+			w.Write([]byte(`{"srpId": ["SRP_1"]}`))
+		case prefix + "/srp/SRP_1":
+			w.Write([]byte(`{"srpId": "SRP_1", "srp_capacity": {"usable_total_tb": 100.5, "usable_used_tb": 40.2, "subscribed_total_tb": 150}, "srp_efficiency": {"data_reduction_ratio_to_one": 2.1}}`))
+		case prefix + "/storagegroup/oracle_sg":
+			w.Write([]byte(`{"storageGroupId": "oracle_sg", "srp": "SRP_1", "cap_gb": 200, "vp_saved_percent": 75}`))
+		case prefix + "/storagegroup/sg#1":
+			if r.URL.EscapedPath() != prefix+"/storagegroup/sg%231" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"storageGroupId": "sg#1", "cap_gb": 10}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer done()
+
+	srps, err := pmax.GetSRPCapacities()
+	FailIfError(t, err)
+	if len(srps) != 1 || srps[0].Capacity.UsableUsedTB != 40.2 || srps[0].Efficiency.DataReductionRatio != 2.1 {
+		t.Errorf("Unexpected SRP capacities %+v", srps)
+	}
+
+	sg, err := pmax.GetStorageGroupCapacity("oracle_sg")
+	FailIfError(t, err)
+	if sg.CapGB != 200 || sg.UsedGB != 50 {
+		t.Errorf("Unexpected storage group capacity %+v", sg)
+	}
+
+	// Storage group IDs are escaped in the URL path
+	sg, err = pmax.GetStorageGroupCapacity("sg#1")
+	FailIfError(t, err)
+	if sg.CapGB != 10 {
+		t.Errorf("Unexpected storage group capacity %+v", sg)
+	}
+}
+
+func TestContext(t *testing.T) {
+	pmax, done := fakeUnisphere(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := pmax.WithContext(ctx).GetArrayCapacity()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expect deadline exceeded, got %v", err)
+	}
+
+	pmax.SetTimeout(100 * time.Millisecond)
+	if _, err := pmax.GetArrayCapacity(); err == nil {
+		t.Error("Expect the request to time out")
+	}
+}
+
+func TestOptions(t *testing.T) {
+	var attempts int
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		// Drop the connection of the first attempt
+		if attempts == 1 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Write([]byte(`{"symmetrixId": "000197900123"}`))
+	}))
+	defer ts.Close()
+
+	addr := strings.Split(strings.TrimPrefix(ts.URL, "https://"), ":")
+	_, err := New(addr[0], "8443", "smc", "smc", "000197900123",
+		WithPort(addr[1]),
+		WithHTTPClient(ts.Client()),
+		WithTimeout(time.Second),
+		WithRetry(utils.RetryPolicy{MaxAttempts: 2, Interval: 10 * time.Millisecond}),
+	)
+	FailIfError(t, err)
+	if attempts != 2 {
+		t.Errorf("Expect 2 attempts, got %d", attempts)
+	}
+
+	// The certificate of the stand-in server is not trusted without options
+	_, err = New(addr[0], "8443", "smc", "smc", "000197900123", WithPort(addr[1]))
+	if err == nil {
+		t.Error("Expect the certificate verification to fail")
+	}
+}
+
+func TestNoSecretLogged(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetLevel(logrus.TraceLevel)
+	defer func(previous *logrus.Logger) { utils.Logger = previous }(utils.Logger)
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"symmetrixId": "000197900123"}`))
+	}))
+	defer ts.Close()
+
+	addr := strings.Split(strings.TrimPrefix(ts.URL, "https://"), ":")
+	pmax, err := New(addr[0], addr[1], "smc", "Unisphere-Pa55", "000197900123", WithInsecure(), WithLogger(logger))
+	FailIfError(t, err)
+	_, err = pmax.GetArrayCapacity()
+	FailIfError(t, err)
+
+	output := buf.String()
+	for _, secret := range []string{"Unisphere-Pa55", "Basic c21j"} {
+		if strings.Contains(output, secret) {
+			t.Errorf("Secret %s is found in the log:\n%s", secret, output)
+		}
+	}
+}
+
+func TestCollector(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/univmax/restapi/system/symmetrix/000197900123":
+			w.Write([]byte(`{"symmetrixId": "000197900123"}`))
+		case "/univmax/restapi/performance/StorageGroup/keys":
+			w.Write([]byte(`{"storageGroupInfo": [{"storageGroupId": "oracle_sg", "firstAvailableDate": 1600000000000, "lastAvailableDate": 1600000300000}]}`))
+		case "/univmax/restapi/performance/Array/metrics":
+			w.Write([]byte(`{"resultList": {"result": [{"HostIOs": 1200, "FEUtilization": 35.5, "timestamp": 1600000300000}]}}`))
+		case "/univmax/restapi/performance/StorageGroup/metrics":
+			// No sample yet within the time window
+			w.Write([]byte(`{"resultList": {"result": []}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	addr := strings.Split(strings.TrimPrefix(ts.URL, "https://"), ":")
+	c := NewCollector(addr[0], addr[1], "smc", "smc", "000197900123").WithOptions(WithInsecure())
+	if _, err := c.Resources(); err == nil {
+		t.Error("Expect an error before connecting")
+	}
+	FailIfError(t, c.Connect())
+	defer c.Close()
+
+	resources, err := c.Resources()
+	FailIfError(t, err)
+	if len(resources) != 2 || resources[0] != (storage.Resource{Kind: storage.KindArray, ID: "000197900123", Name: "000197900123"}) ||
+		resources[1] != (storage.Resource{Kind: storage.KindStorageGroup, ID: "oracle_sg", Name: "oracle_sg"}) {
+		t.Errorf("Unexpected resources %+v", resources)
+	}
+
+	samples, err := c.Collect(timestampToDate(1600000000000), timestampToDate(1600000600000))
+	FailIfError(t, err)
+	var found int
+	for _, s := range samples {
+		if s.Kind != storage.KindArray || s.ID != "000197900123" || s.Labels["array"] != "000197900123" || !s.Timestamp.Equal(timestampToDate(1600000300000)) {
+			t.Errorf("Unexpected sample %+v", s)
+		}
+		if (s.Metric == "HostIOs" && s.Value == 1200 && s.Unit == "IO/s") || (s.Metric == "FEUtilization" && s.Value == 35.5 && s.Unit == "%") {
+			found++
+		}
+	}
+	if found != 2 {
+		t.Errorf("Unexpected samples %+v", samples)
+	}
+
+	// Labels of one sample must not leak into the others
+	samples[0].Labels["extra"] = "x"
+	if samples[1].Labels["extra"] != "" {
+		t.Error("Samples share the same labels")
+	}
 }
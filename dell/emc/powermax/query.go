@@ -0,0 +1,179 @@
+package powermax
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/kckecheng/storagemetric/utils"
+)
+
+// Data formats supported by Unisphere performance queries
+const (
+	DataFormatAverage = "Average"
+	DataFormatMaximum = "Maximum"
+)
+
+// MetricQuery Performance query of a Unisphere category
+type MetricQuery struct {
+	// Category Unisphere performance category, e.g. Array, StorageGroup, FEDirector
+	Category string
+	// Keys Keys identifying the object besides symmetrixId, e.g. {"storageGroupId": "sg1"}
+	Keys map[string]string
+	// Metrics Unisphere metric names, e.g. PercentCacheWP, BEReqs, HostMBs
+	Metrics []string
+	// DataFormat DataFormatAverage or DataFormatMaximum, DataFormatAverage as default
+	DataFormat string
+	From       time.Time
+	To         time.Time
+}
+
+// MetricValues Metric values of a timestamp
+type MetricValues struct {
+	Timestamp int64              `json:"timestamp"`
+	Values    map[string]float64 `json:"values"`
+}
+
+// GetMetrics Get arbitrary metrics of a performance category within the time range ordered by timestamp,
+// non-numeric values are skipped, ErrNoData is returned if there is no sample
+func (pmax *PowerMax) GetMetrics(query MetricQuery) ([]MetricValues, error) {
+	var raw []map[string]interface{}
+	err := pmax.queryMetrics(query, &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := make([]MetricValues, 0, len(raw))
+	for _, entry := range raw {
+		var ts int64
+		values := map[string]float64{}
+		for name, v := range entry {
+			// Metrics not supported by the object are returned as null or strings
+			f, ok := v.(float64)
+			if !ok {
+				continue
+			}
+			if name == "timestamp" {
+				ts = int64(f)
+			} else {
+				values[name] = f
+			}
+		}
+		metrics = append(metrics, MetricValues{Timestamp: ts, Values: values})
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Timestamp < metrics[j].Timestamp })
+	return metrics, nil
+}
+
+// Performance query of a single object of a category, e.g. a disk identified by diskId
+type objectQuery struct {
+	category string
+	key      string
+	metrics  []string
+}
+
+// Build the query of the object identified by id within the time range
+func (q objectQuery) with(id string, from time.Time, to time.Time) MetricQuery {
+	return MetricQuery{Category: q.category, Keys: map[string]string{q.key: id}, Metrics: q.metrics, From: from, To: to}
+}
+
+// Query samples of a single object into series in the data format bound to the object, series must be a pointer
+// to a slice of structs carrying a Timestamp field. The samples are averaged into metric unless it is nil
+func (pmax *PowerMax) queryObject(query MetricQuery, series interface{}, metric interface{}) error {
+	query.DataFormat = pmax.dataFormat
+	err := pmax.queryMetrics(query, series)
+	if err != nil || metric == nil {
+		return err
+	}
+	return utils.Aggregate(reflect.ValueOf(series).Elem().Interface(), utils.AggAvg, metric)
+}
+
+// Send a key query of a performance category and return the idField value of each entry under infoField,
+// e.g. category BEDirector returns {"beDirectorInfo": [{"directorId": "DF-1C", ...}]}
+func (pmax *PowerMax) queryKeys(category string, keys map[string]string, infoField string, idField string) ([]string, error) {
+	payload := map[string]string{"symmetrixId": pmax.symmid}
+	for k, v := range keys {
+		payload[k] = v
+	}
+
+	ret := map[string][]map[string]interface{}{}
+	err := pmax.Request("POST", fmt.Sprintf("/univmax/restapi/performance/%s/keys", category), payload, &ret)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, info := range ret[infoField] {
+		if id, ok := info[idField].(string); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// Send a performance query and decode the result list into result, which must be a pointer to a slice
+// of structs carrying a Timestamp field, or a pointer to a slice of maps
+func (pmax *PowerMax) queryMetrics(query MetricQuery, result interface{}) error {
+	if query.Category == "" || len(query.Metrics) == 0 {
+		return errors.New("category and metrics must be specified")
+	}
+
+	format := query.DataFormat
+	if format == "" {
+		format = DataFormatAverage
+	}
+	if format != DataFormatAverage && format != DataFormatMaximum {
+		return fmt.Errorf("unsupported data format %s", format)
+	}
+
+	payload := map[string]interface{}{
+		"symmetrixId": pmax.symmid,
+		"dataFormat":  format,
+		"startDate":   dateToTimestamp(query.From),
+		"endDate":     dateToTimestamp(query.To),
+		"metrics":     query.Metrics,
+	}
+	for k, v := range query.Keys {
+		payload[k] = v
+	}
+
+	ret := struct {
+		ResultList struct {
+			Result json.RawMessage `json:"result"`
+			From   int64           `json:"from"`
+			To     int64           `json:"to"`
+		} `json:"resultList"`
+		Id             string `json:"id"`
+		Count          int64  `json:"count"`
+		ExpirationTime int64  `json:"expirationTime"`
+		MaxPageSize    int64  `json:"maxPageSize"`
+		WarningMessage string `json:"warningMessage"`
+	}{}
+	uri := fmt.Sprintf("/univmax/restapi/performance/%s/metrics", query.Category)
+	err := pmax.Request("POST", uri, payload, &ret)
+	if err != nil {
+		return err
+	}
+
+	if len(ret.ResultList.Result) == 0 {
+		return ErrNoData
+	}
+	err = json.Unmarshal(ret.ResultList.Result, result)
+	if err != nil {
+		return &RequestError{Method: "POST", URI: uri, StatusCode: 200, Message: err.Error(), Err: ErrDecode}
+	}
+
+	rv := reflect.ValueOf(result).Elem()
+	if rv.Len() == 0 {
+		return ErrNoData
+	}
+	if rv.Type().Elem().Kind() == reflect.Struct {
+		sort.SliceStable(rv.Interface(), func(i, j int) bool {
+			return rv.Index(i).FieldByName("Timestamp").Int() < rv.Index(j).FieldByName("Timestamp").Int()
+		})
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package powermax
+
+import (
+	"time"
+
+	"github.com/kckecheng/storagemetric/utils"
+)
+
+// RDF group modes
+const (
+	RDFModeAsync = "RDFA"
+	RDFModeSync  = "RDFS"
+)
+
+// RDFGroup SRDF group with its replication mode
+type RDFGroup struct {
+	Id   string `json:"raGroupId"`
+	Mode string `json:"mode"`
+}
+
+type RDFAGroupMetric struct {
+	AvgCycleTime        float64 `json:"AvgCycleTime"`
+	DurationOfLastCycle float64 `json:"DurationOfLastCycle"`
+	AvgCycleSize        float64 `json:"AvgCycleSize"`
+	BytesInCache        float64 `json:"BytesInCache"`
+	HostWrites          float64 `json:"HostWrites"`
+	HostMBWritten       float64 `json:"HostMBWritten"`
+	RDFResponseTime     float64 `json:"RDFResponseTime"`
+	Timestamp           int64   `json:"timestamp"`
+}
+
+type RDFSGroupMetric struct {
+	HostWrites      float64 `json:"HostWrites"`
+	HostMBWritten   float64 `json:"HostMBWritten"`
+	RDFWrites       float64 `json:"RDFWrites"`
+	RDFMBWritten    float64 `json:"RDFMBWritten"`
+	ResponseTime    float64 `json:"ResponseTime"`
+	RDFResponseTime float64 `json:"RDFResponseTime"`
+	Timestamp       int64   `json:"timestamp"`
+}
+
+// GetRDFGroups List available SRDF/A and SRDF/S groups
+func (pmax *PowerMax) GetRDFGroups() ([]RDFGroup, error) {
+	var groups []RDFGroup
+
+	for _, mode := range []string{RDFModeAsync, RDFModeSync} {
+		infoField := "rdfaInfo"
+		if mode == RDFModeSync {
+			infoField = "rdfsInfo"
+		}
+		ids, err := pmax.queryKeys(mode, nil, infoField, "raGroupId")
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			groups = append(groups, RDFGroup{Id: id, Mode: mode})
+		}
+	}
+	return groups, nil
+}
+
+// GetRDFAGroupMetricSeries Get all metric samples of a SRDF/A group within the time range ordered by timestamp,
+// ErrNoData is returned if there is no sample
+func (pmax *PowerMax) GetRDFAGroupMetricSeries(group string, from time.Time, to time.Time) ([]RDFAGroupMetric, error) {
+	var metrics []RDFAGroupMetric
+	query := MetricQuery{
+		Category:   RDFModeAsync,
+		Keys:       map[string]string{"raGroupId": group},
+		Metrics:    []string{"AvgCycleTime", "DurationOfLastCycle", "AvgCycleSize", "BytesInCache", "HostWrites", "HostMBWritten", "RDFResponseTime"},
+		DataFormat: pmax.dataFormat,
+		From:       from,
+		To:         to,
+	}
+	err := pmax.queryMetrics(query, &metrics)
+	return metrics, err
+}
+
+// GetRDFAGroupMetric Get the average metric of a SRDF/A group within the time range with the latest timestamp,
+// ErrNoData is returned if there is no sample
+func (pmax *PowerMax) GetRDFAGroupMetric(group string, from time.Time, to time.Time) (RDFAGroupMetric, error) {
+	var metric RDFAGroupMetric
+	metrics, err := pmax.GetRDFAGroupMetricSeries(group, from, to)
+	if err != nil {
+		return metric, err
+	}
+	err = utils.Aggregate(metrics, utils.AggAvg, &metric)
+	return metric, err
+}
+
+// GetRDFSGroupMetricSeries Get all metric samples of a SRDF/S group within the time range ordered by timestamp,
+// ErrNoData is returned if there is no sample
+func (pmax *PowerMax) GetRDFSGroupMetricSeries(group string, from time.Time, to time.Time) ([]RDFSGroupMetric, error) {
+	var metrics []RDFSGroupMetric
+	query := MetricQuery{
+		Category:   RDFModeSync,
+		Keys:       map[string]string{"raGroupId": group},
+		Metrics:    []string{"HostWrites", "HostMBWritten", "RDFWrites", "RDFMBWritten", "ResponseTime", "RDFResponseTime"},
+		DataFormat: pmax.dataFormat,
+		From:       from,
+		To:         to,
+	}
+	err := pmax.queryMetrics(query, &metrics)
+	return metrics, err
+}
+
+// GetRDFSGroupMetric Get the average metric of a SRDF/S group within the time range with the latest timestamp,
+// ErrNoData is returned if there is no sample
+func (pmax *PowerMax) GetRDFSGroupMetric(group string, from time.Time, to time.Time) (RDFSGroupMetric, error) {
+	var metric RDFSGroupMetric
+	metrics, err := pmax.GetRDFSGroupMetricSeries(group, from, to)
+	if err != nil {
+		return metric, err
+	}
+	err = utils.Aggregate(metrics, utils.AggAvg, &metric)
+	return metric, err
+}
@@ -0,0 +1,104 @@
+package unity
+
+import (
+	"time"
+
+	"github.com/kckecheng/storagemetric/storage"
+)
+
+// GetCapacitySamples Collect pool, LUN and file system capacity in bytes and data reduction ratios as samples
+func (unity *Unity) GetCapacitySamples() ([]storage.Sample, error) {
+	system, err := unity.GetSystem()
+	if err != nil {
+		return nil, err
+	}
+	pools, err := unity.GetPools()
+	if err != nil {
+		return nil, err
+	}
+	luns, err := unity.GetLUNs()
+	if err != nil {
+		return nil, err
+	}
+	filesystems, err := unity.GetFilesystems()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var samples []storage.Sample
+	add := func(kind string, id string, base map[string]string, metric string, unit string, value float64) {
+		labels := map[string]string{"array": system.SerialNumber}
+		for k, v := range base {
+			labels[k] = v
+		}
+		samples = append(samples, storage.Sample{
+			Kind:      kind,
+			ID:        id,
+			Metric:    metric,
+			Unit:      unit,
+			Timestamp: now,
+			Value:     value,
+			Labels:    labels,
+		})
+	}
+
+	for _, p := range pools {
+		labels := map[string]string{"pool": p.Id, "name": p.Name}
+		add(storage.KindPool, p.Id, labels, "sizeTotal", "B", float64(p.SizeTotal))
+		add(storage.KindPool, p.Id, labels, "sizeUsed", "B", float64(p.SizeUsed))
+		add(storage.KindPool, p.Id, labels, "sizeFree", "B", float64(p.SizeFree))
+		add(storage.KindPool, p.Id, labels, "sizeSubscribed", "B", float64(p.SizeSubscribed))
+		add(storage.KindPool, p.Id, labels, "dataReductionSizeSaved", "B", float64(p.DataReductionSizeSaved))
+		add(storage.KindPool, p.Id, labels, "dataReductionRatio", "ratio", p.DataReductionRatio)
+	}
+	for _, l := range luns {
+		labels := map[string]string{"lun": l.Id, "name": l.Name, "pool": l.Pool.Id}
+		add(storage.KindLUN, l.Id, labels, "sizeTotal", "B", float64(l.SizeTotal))
+		add(storage.KindLUN, l.Id, labels, "sizeAllocated", "B", float64(l.SizeAllocated))
+		add(storage.KindLUN, l.Id, labels, "dataReductionRatio", "ratio", l.DataReductionRatio)
+	}
+	for _, f := range filesystems {
+		labels := map[string]string{"filesystem": f.Id, "name": f.Name, "pool": f.Pool.Id}
+		add(storage.KindFilesystem, f.Id, labels, "sizeTotal", "B", float64(f.SizeTotal))
+		add(storage.KindFilesystem, f.Id, labels, "sizeUsed", "B", float64(f.SizeUsed))
+		add(storage.KindFilesystem, f.Id, labels, "sizeAllocated", "B", float64(f.SizeAllocated))
+		add(storage.KindFilesystem, f.Id, labels, "dataReductionRatio", "ratio", f.DataReductionRatio)
+	}
+	return samples, nil
+}
+
+// PoolDaysUntilFull Estimate the days until each pool is full from capacity samples collected over time,
+// pools with less than 2 samples are skipped and +Inf means the pool usage is not growing
+func PoolDaysUntilFull(samples []storage.Sample) map[string]float64 {
+	used := map[string][]storage.Sample{}
+	total := map[string]storage.Sample{}
+	for _, s := range samples {
+		if s.Kind != storage.KindPool {
+			continue
+		}
+		switch s.Metric {
+		case "sizeUsed":
+			used[s.ID] = append(used[s.ID], s)
+		case "sizeTotal":
+			// Pools may be expanded, use the latest total size
+			if prev, ok := total[s.ID]; !ok || prev.Timestamp.Before(s.Timestamp) {
+				total[s.ID] = s
+			}
+		}
+	}
+
+	days := map[string]float64{}
+	for id, series := range used {
+		size, ok := total[id]
+		if !ok {
+			continue
+		}
+		d, err := storage.DaysUntil(series, size.Value)
+		if err != nil {
+			continue
+		}
+		days[id] = d
+	}
+	return days
+}
@@ -0,0 +1,180 @@
+package unity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kckecheng/storagemetric/utils"
+)
+
+// Metric types defined by Unity MetricTypeEnum
+const (
+	MetricTypeCounter32        = 2
+	MetricTypeCounter64        = 3
+	MetricTypeRate             = 4
+	MetricTypeFact             = 5
+	MetricTypeText             = 6
+	MetricTypeVirtualCounter32 = 7
+	MetricTypeVirtualCounter64 = 8
+)
+
+// Metric visibilities defined by Unity MetricVisibilityEnum
+const (
+	MetricVisibilityEngineering = 1
+	MetricVisibilityCustomer    = 2
+)
+
+var metricTypeNames = map[int]string{
+	MetricTypeCounter32:        "counter32",
+	MetricTypeCounter64:        "counter64",
+	MetricTypeRate:             "rate",
+	MetricTypeFact:             "fact",
+	MetricTypeText:             "text",
+	MetricTypeVirtualCounter32: "virtualCounter32",
+	MetricTypeVirtualCounter64: "virtualCounter64",
+}
+
+// MetricInfo Definition of a Unity metric path
+type MetricInfo struct {
+	Id                    int    `json:"id"`
+	Name                  string `json:"name"`
+	Path                  string `json:"path"`
+	Type                  int    `json:"type"`
+	Description           string `json:"description"`
+	IsHistoricalAvailable bool   `json:"isHistoricalAvailable"`
+	IsRealtimeAvailable   bool   `json:"isRealtimeAvailable"`
+	UnitDisplayString     string `json:"unitDisplayString"`
+	Visibility            int    `json:"visibility"`
+}
+
+// TypeName Name of the metric type, e.g. counter64, rate, fact
+func (m MetricInfo) TypeName() string {
+	if name, ok := metricTypeNames[m.Type]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", m.Type)
+}
+
+// IsCounter Whether the metric is a cumulative counter
+func (m MetricInfo) IsCounter() bool {
+	switch m.Type {
+	case MetricTypeCounter32, MetricTypeCounter64, MetricTypeVirtualCounter32, MetricTypeVirtualCounter64:
+		return true
+	}
+	return false
+}
+
+// IsRate Whether the metric is a rate
+func (m MetricInfo) IsRate() bool {
+	return m.Type == MetricTypeRate
+}
+
+// IsFact Whether the metric is a fact, e.g. a size or a configuration value
+func (m MetricInfo) IsFact() bool {
+	return m.Type == MetricTypeFact
+}
+
+// MetricCatalog Metric paths available on a Unity
+type MetricCatalog struct {
+	metrics []MetricInfo
+}
+
+// GetMetricCatalog List all available metric paths
+func (unity *Unity) GetMetricCatalog() (*MetricCatalog, error) {
+	utils.Log("debug", "Get metric catalog")
+	catalog := &MetricCatalog{}
+	fields := Fields("id", "name", "path", "type", "description", "isHistoricalAvailable", "isRealtimeAvailable", "unitDisplayString", "visibility")
+	it := unity.NewIterator("/api/types/metric/instances", fields, "", unity.paging)
+	for it.Next() {
+		entry := struct {
+			Content MetricInfo `json:"content"`
+		}{}
+		if err := it.Decode(&entry); err != nil {
+			return nil, err
+		}
+		catalog.metrics = append(catalog.metrics, entry.Content)
+	}
+	if err := it.Err(); err != nil {
+		utils.Log("error", "Fail to get the metric catalog")
+		return nil, err
+	}
+	sort.Slice(catalog.metrics, func(i, j int) bool { return catalog.metrics[i].Path < catalog.metrics[j].Path })
+	return catalog, nil
+}
+
+// NewMetricCatalog Build a catalog from known metric definitions
+func NewMetricCatalog(metrics []MetricInfo) *MetricCatalog {
+	catalog := &MetricCatalog{metrics: append([]MetricInfo(nil), metrics...)}
+	sort.Slice(catalog.metrics, func(i, j int) bool { return catalog.metrics[i].Path < catalog.metrics[j].Path })
+	return catalog
+}
+
+// All List all metrics ordered by path
+func (c *MetricCatalog) All() []MetricInfo {
+	return append([]MetricInfo(nil), c.metrics...)
+}
+
+// Filter List metrics for which keep returns true
+func (c *MetricCatalog) Filter(keep func(MetricInfo) bool) []MetricInfo {
+	var metrics []MetricInfo
+	for _, m := range c.metrics {
+		if keep(m) {
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics
+}
+
+// Search List metrics whose path, name or description contains the keyword, case insensitive
+func (c *MetricCatalog) Search(keyword string) []MetricInfo {
+	keyword = strings.ToLower(keyword)
+	return c.Filter(func(m MetricInfo) bool {
+		return strings.Contains(strings.ToLower(m.Path), keyword) ||
+			strings.Contains(strings.ToLower(m.Name), keyword) ||
+			strings.Contains(strings.ToLower(m.Description), keyword)
+	})
+}
+
+// Lookup Find the definition of a path, wildcards of the definition match any object,
+// e.g. sp.spa.storage.lun.sv_1.reads matches sp.*.storage.lun.*.reads
+func (c *MetricCatalog) Lookup(path string) (MetricInfo, bool) {
+	for _, m := range c.metrics {
+		if matchPath(m.Path, path) {
+			return m, true
+		}
+	}
+	return MetricInfo{}, false
+}
+
+// Validate Check if all paths exist and are available for real time or historical queries
+func (c *MetricCatalog) Validate(paths []string, realtime bool) error {
+	for _, path := range paths {
+		m, ok := c.Lookup(path)
+		if !ok {
+			return fmt.Errorf("metric path %s does not exist", path)
+		}
+		if realtime && !m.IsRealtimeAvailable {
+			return fmt.Errorf("metric path %s is not available for real time queries", path)
+		}
+		if !realtime && !m.IsHistoricalAvailable {
+			return fmt.Errorf("metric path %s is not available for historical queries", path)
+		}
+	}
+	return nil
+}
+
+// Check if a path matches a metric definition segment by segment
+func matchPath(definition string, path string) bool {
+	defSegs := strings.Split(definition, ".")
+	pathSegs := strings.Split(path, ".")
+	if len(defSegs) != len(pathSegs) {
+		return false
+	}
+	for i := range defSegs {
+		if defSegs[i] != "*" && defSegs[i] != pathSegs[i] {
+			return false
+		}
+	}
+	return true
+}
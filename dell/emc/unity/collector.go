@@ -0,0 +1,156 @@
+package unity
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/kckecheng/storagemetric/storage"
+)
+
+// DefaultCollectorPaths Historical metric paths collected by default
+var DefaultCollectorPaths = []string{
+	"sp.*.cpu.summary.utilization",
+	"sp.*.storage.summary.readsRate",
+	"sp.*.storage.summary.writesRate",
+	"sp.*.storage.summary.readBytesRate",
+	"sp.*.storage.summary.writeBytesRate",
+}
+
+// Units of the default metric paths
+var pathUnits = map[string]string{
+	"sp.*.cpu.summary.utilization":        "%",
+	"sp.*.storage.summary.readsRate":      "IO/s",
+	"sp.*.storage.summary.writesRate":     "IO/s",
+	"sp.*.storage.summary.readBytesRate":  "B/s",
+	"sp.*.storage.summary.writeBytesRate": "B/s",
+}
+
+// Collector Adapter exposing Unity as a storage.Collector
+type Collector struct {
+	server   string
+	username string
+	password string
+	paths    []string
+	serial   string
+	opts     []Option
+	unity    *Unity
+}
+
+// NewCollector Init a Unity collector, DefaultCollectorPaths are used if no path is specified
+func NewCollector(server string, username string, password string, paths ...string) *Collector {
+	if len(paths) == 0 {
+		paths = DefaultCollectorPaths
+	}
+	return &Collector{
+		server:   server,
+		username: username,
+		password: password,
+		paths:    paths,
+	}
+}
+
+// WithOptions Set options used to login Unity on Connect
+func (c *Collector) WithOptions(opts ...Option) *Collector {
+	c.opts = opts
+	return c
+}
+
+// Connect Login Unity and get the system serial number
+func (c *Collector) Connect() error {
+	unity, err := New(c.server, c.username, c.password, c.opts...)
+	if err != nil {
+		return err
+	}
+
+	system, err := unity.GetSystem()
+	if err != nil {
+		unity.Destroy()
+		return err
+	}
+	c.serial = system.SerialNumber
+	if c.serial == "" {
+		c.serial = c.server
+	}
+
+	c.unity = unity
+	return nil
+}
+
+// Resources List the array and its storage processors
+func (c *Collector) Resources() ([]storage.Resource, error) {
+	if c.unity == nil {
+		return nil, errors.New("Unity collector is not connected")
+	}
+
+	sps, err := c.unity.GetStorageProcessors()
+	if err != nil {
+		return nil, err
+	}
+
+	resources := []storage.Resource{{Kind: storage.KindArray, ID: c.serial, Name: c.serial}}
+	for _, sp := range sps {
+		resources = append(resources, storage.Resource{Kind: storage.KindStorageProcessor, ID: sp.Id, Name: sp.Name})
+	}
+	return resources, nil
+}
+
+// Collect Collect SP samples of the configured historical metric paths
+func (c *Collector) Collect(from time.Time, to time.Time) ([]storage.Sample, error) {
+	return c.CollectContext(context.Background(), from, to)
+}
+
+// CollectContext Collect SP samples of the configured historical metric paths, abort once ctx is done
+func (c *Collector) CollectContext(ctx context.Context, from time.Time, to time.Time) ([]storage.Sample, error) {
+	if c.unity == nil {
+		return nil, errors.New("Unity collector is not connected")
+	}
+	unity := c.unity.WithContext(ctx)
+
+	var samples []storage.Sample
+	for _, path := range c.paths {
+		// Let Unity filter the time range instead of downloading the whole retained history
+		records, err := unity.GetHistoricalMetricRange(path, from, to, HistoricalInterval5Min)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, record := range records {
+			// The object is identified by the last wildcard, e.g. the SP of sp.*.cpu.summary.utilization
+			kind, id := storage.KindArray, c.serial
+			segs := strings.Split(record.Metric, ".")
+			for i := len(segs) - 1; i > 0; i-- {
+				if segs[i] == "*" {
+					kind, id = segs[i-1], record.Labels[segs[i-1]]
+					break
+				}
+			}
+
+			labels := map[string]string{"array": c.serial}
+			for k, v := range record.Labels {
+				labels[k] = v
+			}
+			samples = append(samples, storage.Sample{
+				Kind:      kind,
+				ID:        id,
+				Metric:    path,
+				Unit:      pathUnits[path],
+				Timestamp: record.Timestamp,
+				Value:     record.Value,
+				Labels:    labels,
+			})
+		}
+	}
+	return samples, nil
+}
+
+// Close Logout Unity
+func (c *Collector) Close() error {
+	if c.unity == nil {
+		return nil
+	}
+	err := c.unity.Destroy()
+	c.unity = nil
+	return err
+}
@@ -0,0 +1,113 @@
+package unity
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Time format used by Unity filters
+const timeFormat = "2006-01-02T15:04:05.000Z"
+
+// Filter Unity REST filter expression, e.g. path eq "sp.*.cpu.summary.utilization" and interval eq 300
+type Filter struct {
+	expr string
+	// op Logical operator joining the top level operands, empty for a single condition or a group
+	op string
+}
+
+// String The filter expression to be passed as the filter query parameter
+func (f Filter) String() string {
+	return f.expr
+}
+
+// Quote a value in Unity filter syntax, strings and times are double quoted
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+	case time.Time:
+		return `"` + v.UTC().Format(timeFormat) + `"`
+	case fmt.Stringer:
+		return formatValue(v.String())
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func condition(attr string, op string, value interface{}) Filter {
+	return Filter{expr: fmt.Sprintf("%s %s %s", attr, op, formatValue(value))}
+}
+
+// Eq attr equals value
+func Eq(attr string, value interface{}) Filter {
+	return condition(attr, "eq", value)
+}
+
+// Ne attr does not equal value
+func Ne(attr string, value interface{}) Filter {
+	return condition(attr, "ne", value)
+}
+
+// Lt attr is less than value
+func Lt(attr string, value interface{}) Filter {
+	return condition(attr, "lt", value)
+}
+
+// Gt attr is greater than value
+func Gt(attr string, value interface{}) Filter {
+	return condition(attr, "gt", value)
+}
+
+// Lk attr is like pattern, % matches any characters
+func Lk(attr string, pattern string) Filter {
+	return condition(attr, "lk", pattern)
+}
+
+// Group Wrap a filter in parentheses
+func Group(f Filter) Filter {
+	return Filter{expr: "(" + f.expr + ")"}
+}
+
+func join(op string, filters []Filter) Filter {
+	var exprs []string
+	for _, f := range filters {
+		if f.expr == "" {
+			continue
+		}
+		// Keep the precedence of operands joined by a different operator
+		if f.op != "" && f.op != op {
+			f = Group(f)
+		}
+		exprs = append(exprs, f.expr)
+	}
+
+	if len(exprs) == 1 {
+		return Filter{expr: exprs[0]}
+	}
+	return Filter{expr: strings.Join(exprs, " "+op+" "), op: op}
+}
+
+// And All filters must match, empty filters are ignored
+func And(filters ...Filter) Filter {
+	return join("and", filters)
+}
+
+// Or Any filter must match, empty filters are ignored
+func Or(filters ...Filter) Filter {
+	return join("or", filters)
+}
+
+// Fields Compose the fields query parameter from attribute names, e.g. id,name,pool.name
+func Fields(names ...string) string {
+	var fields []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		fields = append(fields, name)
+	}
+	return strings.Join(fields, ",")
+}
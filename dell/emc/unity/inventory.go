@@ -0,0 +1,299 @@
+package unity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/kckecheng/storagemetric/utils"
+)
+
+var healthNames = map[int]string{
+	0:  "UNKNOWN",
+	5:  "OK",
+	7:  "OK_BUT",
+	10: "DEGRADED",
+	15: "MINOR",
+	20: "MAJOR",
+	25: "CRITICAL",
+	30: "NON_RECOVERABLE",
+}
+
+// Health Health of an object
+type Health struct {
+	Value          int      `json:"value"`
+	DescriptionIds []string `json:"descriptionIds"`
+	Descriptions   []string `json:"descriptions"`
+}
+
+// String Name of the health value, e.g. OK, DEGRADED
+func (h Health) String() string {
+	if name, ok := healthNames[h.Value]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", h.Value)
+}
+
+// Reference Reference to another object
+type Reference struct {
+	Id string `json:"id"`
+}
+
+type System struct {
+	Id           string `json:"id"`
+	Name         string `json:"name"`
+	Model        string `json:"model"`
+	SerialNumber string `json:"serialNumber"`
+	Platform     string `json:"platform"`
+	MacAddress   string `json:"macAddress"`
+	Health       Health `json:"health"`
+}
+
+type StorageProcessor struct {
+	Id              string `json:"id"`
+	Name            string `json:"name"`
+	Model           string `json:"model"`
+	EmcSerialNumber string `json:"emcSerialNumber"`
+	MemorySize      uint64 `json:"memorySize"`
+	IsRescueMode    bool   `json:"isRescueMode"`
+	Health          Health `json:"health"`
+}
+
+type Pool struct {
+	Id                     string  `json:"id"`
+	Name                   string  `json:"name"`
+	Description            string  `json:"description"`
+	RaidType               int     `json:"raidType"`
+	SizeTotal              uint64  `json:"sizeTotal"`
+	SizeUsed               uint64  `json:"sizeUsed"`
+	SizeFree               uint64  `json:"sizeFree"`
+	SizeSubscribed         uint64  `json:"sizeSubscribed"`
+	DataReductionSizeSaved uint64  `json:"dataReductionSizeSaved"`
+	DataReductionRatio     float64 `json:"dataReductionRatio"`
+	Health                 Health  `json:"health"`
+}
+
+type LUN struct {
+	Id                 string    `json:"id"`
+	Name               string    `json:"name"`
+	Description        string    `json:"description"`
+	Type               int       `json:"type"`
+	Wwn                string    `json:"wwn"`
+	SizeTotal          uint64    `json:"sizeTotal"`
+	SizeAllocated      uint64    `json:"sizeAllocated"`
+	IsThinEnabled      bool      `json:"isThinEnabled"`
+	DataReductionRatio float64   `json:"dataReductionRatio"`
+	Pool               Reference `json:"pool"`
+	Health             Health    `json:"health"`
+}
+
+type Filesystem struct {
+	Id                 string    `json:"id"`
+	Name               string    `json:"name"`
+	Description        string    `json:"description"`
+	SizeTotal          uint64    `json:"sizeTotal"`
+	SizeUsed           uint64    `json:"sizeUsed"`
+	SizeAllocated      uint64    `json:"sizeAllocated"`
+	IsThinEnabled      bool      `json:"isThinEnabled"`
+	DataReductionRatio float64   `json:"dataReductionRatio"`
+	Pool               Reference `json:"pool"`
+	Health             Health    `json:"health"`
+}
+
+type Disk struct {
+	Id              string    `json:"id"`
+	Name            string    `json:"name"`
+	Model           string    `json:"model"`
+	EmcSerialNumber string    `json:"emcSerialNumber"`
+	DiskTechnology  int       `json:"diskTechnology"`
+	TierType        int       `json:"tierType"`
+	Size            uint64    `json:"size"`
+	RawSize         uint64    `json:"rawSize"`
+	Pool            Reference `json:"pool"`
+	Health          Health    `json:"health"`
+}
+
+type FCPort struct {
+	Id               string    `json:"id"`
+	Name             string    `json:"name"`
+	Wwn              string    `json:"wwn"`
+	SlotNumber       int       `json:"slotNumber"`
+	CurrentSpeed     int       `json:"currentSpeed"`
+	StorageProcessor Reference `json:"storageProcessor"`
+	Health           Health    `json:"health"`
+}
+
+type EthernetPort struct {
+	Id               string    `json:"id"`
+	Name             string    `json:"name"`
+	PortNumber       int       `json:"portNumber"`
+	MacAddress       string    `json:"macAddress"`
+	Speed            int       `json:"speed"`
+	IsLinkUp         bool      `json:"isLinkUp"`
+	StorageProcessor Reference `json:"storageProcessor"`
+	Health           Health    `json:"health"`
+}
+
+// Compose the fields parameter from the json tags of a struct type
+func structFields(t reflect.Type) string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		if name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return Fields(names...)
+}
+
+// List all instances of a resource type into result, which must be a pointer to a slice of structs
+func (unity *Unity) listInstances(resourceType string, filter Filter, result interface{}) error {
+	rv := reflect.ValueOf(result)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return errors.New("result must be a pointer to a slice")
+	}
+	list := rv.Elem()
+	elemType := list.Type().Elem()
+
+	utils.Log("debug", fmt.Sprintf("List %s instances", resourceType))
+	it := unity.NewIterator(fmt.Sprintf("/api/types/%s/instances", resourceType), structFields(elemType), filter.String(), unity.paging)
+	for it.Next() {
+		entry := struct {
+			Content json.RawMessage `json:"content"`
+		}{}
+		if err := it.Decode(&entry); err != nil {
+			return err
+		}
+		elem := reflect.New(elemType)
+		if err := json.Unmarshal(entry.Content, elem.Interface()); err != nil {
+			return err
+		}
+		list.Set(reflect.Append(list, elem.Elem()))
+	}
+	return it.Err()
+}
+
+// Get an instance of a resource type into result, which must be a pointer to a struct
+func (unity *Unity) getInstance(resourceType string, id string, result interface{}) error {
+	utils.Log("debug", fmt.Sprintf("Get %s instance %s", resourceType, id))
+	entry := struct {
+		Content json.RawMessage `json:"content"`
+	}{}
+	fields := structFields(reflect.TypeOf(result).Elem())
+	err := unity.Request("GET", fmt.Sprintf("/api/instances/%s/%s", resourceType, id), fields, "", nil, &entry)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(entry.Content, result)
+}
+
+// GetSystem Get the storage system
+func (unity *Unity) GetSystem() (System, error) {
+	var systems []System
+	if err := unity.listInstances("system", Filter{}, &systems); err != nil {
+		return System{}, err
+	}
+	if len(systems) == 0 {
+		return System{}, errors.New("no system instance is returned")
+	}
+	return systems[0], nil
+}
+
+// GetStorageProcessors List storage processors
+func (unity *Unity) GetStorageProcessors() ([]StorageProcessor, error) {
+	var sps []StorageProcessor
+	err := unity.listInstances("storageProcessor", Filter{}, &sps)
+	return sps, err
+}
+
+// GetStorageProcessor Get a storage processor by ID, e.g. spa
+func (unity *Unity) GetStorageProcessor(id string) (StorageProcessor, error) {
+	var sp StorageProcessor
+	err := unity.getInstance("storageProcessor", id, &sp)
+	return sp, err
+}
+
+// GetPools List pools
+func (unity *Unity) GetPools() ([]Pool, error) {
+	var pools []Pool
+	err := unity.listInstances("pool", Filter{}, &pools)
+	return pools, err
+}
+
+// GetPool Get a pool by ID, e.g. pool_1
+func (unity *Unity) GetPool(id string) (Pool, error) {
+	var pool Pool
+	err := unity.getInstance("pool", id, &pool)
+	return pool, err
+}
+
+// GetLUNs List LUNs
+func (unity *Unity) GetLUNs() ([]LUN, error) {
+	var luns []LUN
+	err := unity.listInstances("lun", Filter{}, &luns)
+	return luns, err
+}
+
+// GetLUN Get a LUN by ID, e.g. sv_1
+func (unity *Unity) GetLUN(id string) (LUN, error) {
+	var lun LUN
+	err := unity.getInstance("lun", id, &lun)
+	return lun, err
+}
+
+// GetFilesystems List file systems
+func (unity *Unity) GetFilesystems() ([]Filesystem, error) {
+	var filesystems []Filesystem
+	err := unity.listInstances("filesystem", Filter{}, &filesystems)
+	return filesystems, err
+}
+
+// GetFilesystem Get a file system by ID, e.g. fs_1
+func (unity *Unity) GetFilesystem(id string) (Filesystem, error) {
+	var filesystem Filesystem
+	err := unity.getInstance("filesystem", id, &filesystem)
+	return filesystem, err
+}
+
+// GetDisks List disks
+func (unity *Unity) GetDisks() ([]Disk, error) {
+	var disks []Disk
+	err := unity.listInstances("disk", Filter{}, &disks)
+	return disks, err
+}
+
+// GetDisk Get a disk by ID, e.g. dpe_disk_0
+func (unity *Unity) GetDisk(id string) (Disk, error) {
+	var disk Disk
+	err := unity.getInstance("disk", id, &disk)
+	return disk, err
+}
+
+// GetFCPorts List FC ports
+func (unity *Unity) GetFCPorts() ([]FCPort, error) {
+	var ports []FCPort
+	err := unity.listInstances("fcPort", Filter{}, &ports)
+	return ports, err
+}
+
+// GetFCPort Get a FC port by ID, e.g. spa_fc4
+func (unity *Unity) GetFCPort(id string) (FCPort, error) {
+	var port FCPort
+	err := unity.getInstance("fcPort", id, &port)
+	return port, err
+}
+
+// GetEthernetPorts List ethernet ports
+func (unity *Unity) GetEthernetPorts() ([]EthernetPort, error) {
+	var ports []EthernetPort
+	err := unity.listInstances("ethernetPort", Filter{}, &ports)
+	return ports, err
+}
+
+// GetEthernetPort Get an ethernet port by ID, e.g. spa_eth2
+func (unity *Unity) GetEthernetPort(id string) (EthernetPort, error) {
+	var port EthernetPort
+	err := unity.getInstance("ethernetPort", id, &port)
+	return port, err
+}
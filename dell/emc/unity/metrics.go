@@ -1,7 +1,10 @@
 package unity
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/kckecheng/storagemetric/utils"
 )
@@ -36,6 +39,15 @@ func (unity *Unity) MetricRealTimeQueryExisted(id int) bool {
 	return true
 }
 
+// GetMetricRealTimeQuery Get a real time query based on its ID
+func (unity *Unity) GetMetricRealTimeQuery(id int) (MetricRealTimeQuery, error) {
+	utils.Log("debug", fmt.Sprintf("Get metric real time query %d", id))
+	var ret MetricRealTimeQuery
+	fields := Fields("id", "paths", "interval", "maximumSamples", "expiration")
+	err := unity.Request("GET", fmt.Sprintf("/api/instances/metricRealTimeQuery/%d", id), fields, "", nil, &ret)
+	return ret, err
+}
+
 // DeleteMetricRealTimeQuery Delete a real time query based on its ID
 func (unity *Unity) DeleteMetricRealTimeQuery(id int) error {
 	utils.Log("debug", fmt.Sprintf("Delete metric real time query %d", id))
@@ -44,19 +56,154 @@ func (unity *Unity) DeleteMetricRealTimeQuery(id int) error {
 	return err
 }
 
-// GetMetricQueryResult Get metric results
+// ListMetricRealTimeQueries List all existing real time queries, including the ones created by other clients
+func (unity *Unity) ListMetricRealTimeQueries() ([]MetricRealTimeQuery, error) {
+	utils.Log("debug", "List metric real time queries")
+	var queries []MetricRealTimeQuery
+	fields := Fields("id", "paths", "interval", "maximumSamples", "expiration")
+	it := unity.NewIterator("/api/types/metricRealTimeQuery/instances", fields, "", unity.paging)
+	for it.Next() {
+		var query MetricRealTimeQuery
+		if err := it.Decode(&query); err != nil {
+			return nil, err
+		}
+		queries = append(queries, query)
+	}
+	return queries, it.Err()
+}
+
+// QueryCleanupFilter Select real time queries to delete, a query must meet all the specified conditions.
+// Unity records neither the owner nor the creation time of a query, so queries are identified by their
+// paths and interval, and aged by their expiration time.
+type QueryCleanupFilter struct {
+	// Paths Match queries requesting exactly these paths in any order
+	Paths []string
+	// Interval Match queries with this interval in seconds
+	Interval int
+	// ExpiresBefore Match queries expiring before this time
+	ExpiresBefore time.Time
+}
+
+// Match Check if a query meets the filter, an empty filter matches all queries
+func (f QueryCleanupFilter) Match(query MetricRealTimeQuery) bool {
+	if len(f.Paths) > 0 {
+		if len(f.Paths) != len(query.Content.Paths) {
+			return false
+		}
+		expected := append([]string(nil), f.Paths...)
+		actual := append([]string(nil), query.Content.Paths...)
+		sort.Strings(expected)
+		sort.Strings(actual)
+		for i := range expected {
+			if expected[i] != actual[i] {
+				return false
+			}
+		}
+	}
+	if f.Interval != 0 && f.Interval != query.Content.Interval {
+		return false
+	}
+	if !f.ExpiresBefore.IsZero() && !query.Content.Expiration.Before(f.ExpiresBefore) {
+		return false
+	}
+	return true
+}
+
+// CleanupMetricRealTimeQueries Delete real time queries meeting the filter, return the deleted queries
+func (unity *Unity) CleanupMetricRealTimeQueries(filter QueryCleanupFilter) ([]MetricRealTimeQuery, error) {
+	queries, err := unity.ListMetricRealTimeQueries()
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []MetricRealTimeQuery
+	for _, query := range queries {
+		if !filter.Match(query) {
+			continue
+		}
+		if err := unity.DeleteMetricRealTimeQuery(query.Content.Id); err != nil {
+			utils.Log("error", fmt.Sprintf("Fail to delete metric real time query %d", query.Content.Id))
+			return deleted, err
+		}
+		utils.Log("info", fmt.Sprintf("Metric real time query %d is deleted", query.Content.Id))
+		deleted = append(deleted, query)
+	}
+	return deleted, nil
+}
+
+// GetMetricQueryResult Get metric results of all pages
 // Pitfall: Metric will be empty if it is retrivded without waiting for at least a query interval after creating the query
 func (unity *Unity) GetMetricQueryResult(id int, result *Metric) error {
 	utils.Log("debug", fmt.Sprintf("Get metric result with id %d", id))
-	filter := fmt.Sprintf("queryId eq %d", id)
-	err := unity.Request("GET", "/api/types/metricQueryResult/instances", "", filter, nil, result)
-	return err
+	filter := Eq("queryId", id)
+	return unity.getMetrics("/api/types/metricQueryResult/instances", filter, result)
 }
 
-// GetHistoricalMetric Query historial metrics
+// GetHistoricalMetric Query historial metrics of all pages
 func (unity *Unity) GetHistoricalMetric(path string, result *Metric) error {
 	utils.Log("debug", fmt.Sprintf("Get historical metric data with path %s", path))
-	filter := fmt.Sprintf("path eq \"%s\"", path)
-	err := unity.Request("GET", "/api/types/metricValue/instances", "", filter, nil, result)
-	return err
+	filter := Eq("path", path)
+	return unity.getMetrics("/api/types/metricValue/instances", filter, result)
+}
+
+// Intervals in seconds of historical metrics kept by Unity
+const (
+	HistoricalInterval1Min  = 60
+	HistoricalInterval5Min  = 300
+	HistoricalInterval1Hour = 3600
+	HistoricalInterval4Hour = 14400
+)
+
+// GetHistoricalMetricRange Query historical metrics of a path within [from, to] at the specified interval in seconds,
+// records are sorted by time
+func (unity *Unity) GetHistoricalMetricRange(path string, from time.Time, to time.Time, interval int) ([]MetricRecord, error) {
+	utils.Log("debug", fmt.Sprintf("Get historical metric data with path %s from %s to %s with interval %d", path, from, to, interval))
+	switch interval {
+	case HistoricalInterval1Min, HistoricalInterval5Min, HistoricalInterval1Hour, HistoricalInterval4Hour:
+	default:
+		return nil, fmt.Errorf("unsupported historical interval %d", interval)
+	}
+	if to.Before(from) {
+		return nil, errors.New("from must not be later than to")
+	}
+
+	// Unity filters only support strict comparisons for timestamps, widen the range by a millisecond to include both ends
+	filter := And(
+		Eq("path", path),
+		Eq("interval", interval),
+		Gt("timestamp", from.Add(-time.Millisecond)),
+		Lt("timestamp", to.Add(time.Millisecond)),
+	)
+	var ret Metric
+	err := unity.getMetrics("/api/types/metricValue/instances", filter, &ret)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []MetricRecord
+	for _, record := range ret.Records() {
+		if !record.Timestamp.Before(from) && !record.Timestamp.After(to) {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// Collect metric entries of all pages into result, @base, updated and links are taken from the first page
+func (unity *Unity) getMetrics(URI string, filter Filter, result *Metric) error {
+	*result = Metric{}
+	it := unity.NewIterator(URI, "", filter.String(), unity.paging)
+	for it.Next() {
+		var entry MetricEntry
+		if err := it.Decode(&entry); err != nil {
+			return err
+		}
+		result.Entries = append(result.Entries, entry)
+	}
+	if it.first != nil {
+		result.Base = it.first.Base
+		result.Updated = it.first.Updated
+		result.Links = it.first.Links
+	}
+	return it.Err()
 }
@@ -0,0 +1,159 @@
+package unity
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kckecheng/storagemetric/utils"
+)
+
+// PageOptions Control how collection queries are paginated
+type PageOptions struct {
+	// PerPage Entries per page, Unity default page size is used if 0
+	PerPage int
+	// MaxItems Stop after this number of entries, unlimited if 0
+	MaxItems int
+}
+
+// SetPageOptions Set the pagination used by all collection queries of the client
+func (unity *Unity) SetPageOptions(opts PageOptions) {
+	unity.paging = opts
+}
+
+// Iterator Stream entries of a collection page by page, following the next links until exhausted
+type Iterator struct {
+	unity    *Unity
+	uri      string
+	fields   string
+	filter   string
+	opts     PageOptions
+	page     int
+	lastPage bool
+	entries  []json.RawMessage
+	pos      int
+	count    int
+	current  json.RawMessage
+	err      error
+	// Envelope of the first page without entries
+	first *Metric
+}
+
+// NewIterator Create an iterator over the entries of a collection such as /api/types/metricValue/instances
+func (unity *Unity) NewIterator(URI string, fields string, filter string, opts PageOptions) *Iterator {
+	return &Iterator{
+		unity:  unity,
+		uri:    URI,
+		fields: fields,
+		filter: filter,
+		opts:   opts,
+		page:   1,
+	}
+}
+
+// Next Move to the next entry, false is returned once all entries are consumed or an error is met
+func (it *Iterator) Next() bool {
+	if it.err != nil || (it.opts.MaxItems > 0 && it.count >= it.opts.MaxItems) {
+		return false
+	}
+
+	for it.pos >= len(it.entries) {
+		if it.lastPage {
+			return false
+		}
+		if err := it.fetch(); err != nil {
+			it.err = err
+			return false
+		}
+	}
+
+	it.current = it.entries[it.pos]
+	it.pos++
+	it.count++
+	return true
+}
+
+// Entry Raw json of the current entry, e.g. {"content": {...}}
+func (it *Iterator) Entry() json.RawMessage {
+	return it.current
+}
+
+// Decode Decode the current entry into v
+func (it *Iterator) Decode(v interface{}) error {
+	return json.Unmarshal(it.current, v)
+}
+
+// Err The error stopping the iteration if any
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Fetch the current page and locate the next one
+func (it *Iterator) fetch() error {
+	params := map[string]string{"page": strconv.Itoa(it.page)}
+	if it.opts.PerPage > 0 {
+		params["per_page"] = strconv.Itoa(it.opts.PerPage)
+	}
+	if it.fields != "" {
+		params["fields"] = it.fields
+	}
+	if it.filter != "" {
+		params["filter"] = it.filter
+	}
+
+	ret := struct {
+		Base    string            `json:"@base"`
+		Updated time.Time         `json:"updated"`
+		Entries []json.RawMessage `json:"entries"`
+		Links   []struct {
+			Rel  string `json:"rel"`
+			Href string `json:"href"`
+		} `json:"links"`
+	}{}
+	err := it.unity.request(it.unity.context(), "GET", it.uri, params, nil, &ret)
+	if err != nil {
+		return err
+	}
+	it.entries = ret.Entries
+	it.pos = 0
+	if it.first == nil {
+		it.first = &Metric{Base: ret.Base, Updated: ret.Updated, Links: ret.Links}
+	}
+
+	// Stop on an empty page in case the next link never ends
+	it.lastPage = true
+	if len(ret.Entries) == 0 {
+		return nil
+	}
+	for _, link := range ret.Links {
+		if link.Rel != "next" {
+			continue
+		}
+		next, ok := nextPage(link.Href)
+		if ok && next > it.page {
+			utils.Log("debug", fmt.Sprintf("Follow the next page %d of %s", next, it.uri))
+			it.page = next
+			it.lastPage = false
+		}
+	}
+	return nil
+}
+
+// Parse the page number of a next link, which looks like "&page=2" or "/api/types/lun/instances?page=2"
+func nextPage(href string) (int, bool) {
+	if i := strings.Index(href, "?"); i >= 0 {
+		href = href[i+1:]
+	}
+	values, err := url.ParseQuery(strings.TrimLeft(href, "&"))
+	if err != nil {
+		return 0, false
+	}
+	page, err := strconv.Atoi(values.Get("page"))
+	if err != nil {
+		return 0, false
+	}
+	return page, true
+}
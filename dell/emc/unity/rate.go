@@ -0,0 +1,155 @@
+package unity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kckecheng/storagemetric/utils"
+)
+
+// RateCalculator Convert cumulative counters into per second rates by remembering the previous record of each object
+type RateCalculator struct {
+	mutex    sync.Mutex
+	previous map[string]MetricRecord
+}
+
+// NewRateCalculator Init a rate calculator
+func NewRateCalculator() *RateCalculator {
+	return &RateCalculator{previous: map[string]MetricRecord{}}
+}
+
+// Rates Compute per second rates of counter records, records are expected to be fed in time order.
+// The first record of an object only sets the baseline, and so does a counter lower than the previous one,
+// which happens when the counter is reset, e.g. after a SP reboot.
+func (rc *RateCalculator) Rates(records []MetricRecord) []MetricRecord {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	var rates []MetricRecord
+	for _, record := range records {
+		prev, ok := rc.previous[record.Path]
+		if ok && !record.Timestamp.After(prev.Timestamp) {
+			// Duplicated or out of order sample, keep the newer baseline
+			continue
+		}
+		rc.previous[record.Path] = record
+		if !ok {
+			continue
+		}
+
+		if record.Value < prev.Value {
+			utils.Log("info", fmt.Sprintf("Counter %s is reset from %f to %f", record.Path, prev.Value, record.Value))
+			continue
+		}
+
+		rate := record
+		rate.Value = (record.Value - prev.Value) / record.Timestamp.Sub(prev.Timestamp).Seconds()
+		rates = append(rates, rate)
+	}
+	return rates
+}
+
+// Convert Compute rates for counter records based on the catalog, other records are returned as they are
+func (rc *RateCalculator) Convert(records []MetricRecord, catalog *MetricCatalog) []MetricRecord {
+	var counters, converted []MetricRecord
+	for _, record := range records {
+		if m, ok := catalog.Lookup(record.Metric); ok && m.IsCounter() {
+			counters = append(counters, record)
+		} else {
+			converted = append(converted, record)
+		}
+	}
+	return append(converted, rc.Rates(counters)...)
+}
+
+// Reset Forget all previous records
+func (rc *RateCalculator) Reset() {
+	rc.mutex.Lock()
+	rc.previous = map[string]MetricRecord{}
+	rc.mutex.Unlock()
+}
+
+// DerivePercentage Derive part / (part + rest) * 100 as metric from records of the same object and timestamp,
+// e.g. CPU utilization from the rates of busy and idle ticks
+func DerivePercentage(records []MetricRecord, part string, rest string, metric string) []MetricRecord {
+	type pair struct {
+		part, rest *MetricRecord
+	}
+	pairs := map[string]*pair{}
+	var keys []string
+	for i := range records {
+		record := &records[i]
+		if record.Metric != part && record.Metric != rest {
+			continue
+		}
+
+		key := record.Timestamp.String() + labelsKey(record.Labels)
+		p, ok := pairs[key]
+		if !ok {
+			p = &pair{}
+			pairs[key] = p
+			keys = append(keys, key)
+		}
+		if record.Metric == part {
+			p.part = record
+		} else {
+			p.rest = record
+		}
+	}
+
+	var derived []MetricRecord
+	for _, key := range keys {
+		p := pairs[key]
+		if p.part == nil || p.rest == nil || p.part.Value+p.rest.Value == 0 {
+			continue
+		}
+		derived = append(derived, MetricRecord{
+			Path:      resolvePath(metric, p.part.Metric, p.part.Path),
+			Metric:    metric,
+			Value:     p.part.Value / (p.part.Value + p.rest.Value) * 100,
+			Timestamp: p.part.Timestamp,
+			Labels:    p.part.Labels,
+		})
+	}
+	return derived
+}
+
+// CPUUtilization Derive SP CPU utilization in percent from the rates of busy and idle ticks
+func CPUUtilization(rates []MetricRecord) []MetricRecord {
+	return DerivePercentage(rates, "sp.*.cpu.summary.busyTicks", "sp.*.cpu.summary.idleTicks", "sp.*.cpu.summary.utilization")
+}
+
+func labelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString("," + k + "=" + labels[k])
+	}
+	return b.String()
+}
+
+// Substitute the wildcards of metric with the objects resolved in path of the source metric
+func resolvePath(metric string, source string, path string) string {
+	var objs []string
+	sourceSegs, pathSegs := strings.Split(source, "."), strings.Split(path, ".")
+	for i, seg := range sourceSegs {
+		if seg == "*" && i < len(pathSegs) {
+			objs = append(objs, pathSegs[i])
+		}
+	}
+
+	segs := strings.Split(metric, ".")
+	for i, seg := range segs {
+		if seg == "*" && len(objs) > 0 {
+			segs[i], objs = objs[0], objs[1:]
+		}
+	}
+	return strings.Join(segs, ".")
+}
@@ -0,0 +1,105 @@
+package unity
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kckecheng/storagemetric/utils"
+)
+
+// Records Flatten all entries into records ordered by timestamp and path
+func (m *Metric) Records() []MetricRecord {
+	var records []MetricRecord
+	for _, entry := range m.Entries {
+		records = append(records, FlattenValues(entry.Content.Path, entry.Content.Timestamp, entry.Content.Values)...)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Timestamp.Equal(records[j].Timestamp) {
+			return records[i].Path < records[j].Path
+		}
+		return records[i].Timestamp.Before(records[j].Timestamp)
+	})
+	return records
+}
+
+// FlattenValues Flatten values nested by the wildcards of path into records, non numeric leaves are skipped
+func FlattenValues(path string, ts time.Time, values interface{}) []MetricRecord {
+	segs := strings.Split(path, ".")
+
+	// Name each wildcard after the segment before it, e.g. lun for lun.*
+	var wildcards []int
+	names := map[int]string{}
+	used := map[string]int{}
+	for i, seg := range segs {
+		if seg != "*" {
+			continue
+		}
+		name := fmt.Sprintf("object%d", len(wildcards))
+		if i > 0 && segs[i-1] != "*" {
+			name = segs[i-1]
+		}
+		if n := used[name]; n > 0 {
+			name = fmt.Sprintf("%s%d", name, n)
+		}
+		used[name]++
+		names[i] = name
+		wildcards = append(wildcards, i)
+	}
+
+	var records []MetricRecord
+	var walk func(depth int, value interface{}, subst []string)
+	walk = func(depth int, value interface{}, subst []string) {
+		nested, isMap := value.(map[string]interface{})
+		if depth < len(wildcards) && isMap {
+			keys := make([]string, 0, len(nested))
+			for k := range nested {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				walk(depth+1, nested[k], append(append([]string(nil), subst...), k))
+			}
+			return
+		}
+
+		v, ok := toFloat(value)
+		if !ok {
+			utils.Log("debug", fmt.Sprintf("Skip non numeric value %v of %s", value, path))
+			return
+		}
+
+		resolved := append([]string(nil), segs...)
+		labels := map[string]string{}
+		for i, obj := range subst {
+			resolved[wildcards[i]] = obj
+			labels[names[wildcards[i]]] = obj
+		}
+		records = append(records, MetricRecord{
+			Path:      strings.Join(resolved, "."),
+			Metric:    path,
+			Value:     v,
+			Timestamp: ts,
+			Labels:    labels,
+		})
+	}
+	walk(0, values, nil)
+	return records
+}
+
+// Convert a decoded json value into float64, Unity returns 64 bit counters as strings
+func toFloat(v interface{}) (float64, bool) {
+	switch value := v.(type) {
+	case float64:
+		return value, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return 0, false
+		}
+		return f, true
+	}
+	return 0, false
+}
@@ -0,0 +1,169 @@
+package unity
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kckecheng/storagemetric/utils"
+)
+
+// Subscription Managed real time query delivering new records on C until it is closed.
+// The query is re-created before it expires or once it disappears, and is deleted on Close.
+type Subscription struct {
+	// C New records of each poll, closed after the subscription is closed
+	C <-chan []MetricRecord
+
+	unity      *Unity
+	paths      []string
+	interval   int
+	id         int
+	expiration time.Time
+	latest     time.Time
+	records    chan []MetricRecord
+	stop       chan struct{}
+	done       chan struct{}
+	closeOnce  sync.Once
+	mutex      sync.Mutex
+	err        error
+}
+
+// Subscribe Create a managed real time query polled every interval seconds
+func (unity *Unity) Subscribe(paths []string, interval int) (*Subscription, error) {
+	records := make(chan []MetricRecord, 1)
+	sub := &Subscription{
+		C:        records,
+		unity:    unity,
+		paths:    paths,
+		interval: interval,
+		records:  records,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	if err := sub.create(); err != nil {
+		return nil, err
+	}
+	go sub.run()
+	return sub, nil
+}
+
+// Err The last error met while polling, nil if the last poll succeeded
+func (sub *Subscription) Err() error {
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+	return sub.err
+}
+
+// Close Stop polling and delete the query
+func (sub *Subscription) Close() error {
+	var err error
+	sub.closeOnce.Do(func() {
+		close(sub.stop)
+		<-sub.done
+		// Clean up the query even if the context of the subscription is already done
+		err = sub.unity.WithContext(context.Background()).DeleteMetricRealTimeQuery(sub.id)
+	})
+	return err
+}
+
+// Create a new query and delete the previous one if any
+func (sub *Subscription) create() error {
+	id, err := sub.unity.NewMetricRealTimeQuery(sub.paths, sub.interval)
+	if err != nil {
+		return err
+	}
+
+	var expiration time.Time
+	query, err := sub.unity.GetMetricRealTimeQuery(id)
+	if err != nil {
+		utils.Log("warning", fmt.Sprintf("Fail to get the expiration of query %d, it will only be renewed once it disappears", id))
+	} else {
+		expiration = query.Content.Expiration
+	}
+
+	if sub.id != 0 {
+		if err := sub.unity.DeleteMetricRealTimeQuery(sub.id); err != nil {
+			utils.Log("warning", fmt.Sprintf("Fail to delete the previous query %d", sub.id))
+		}
+	}
+	utils.Log("info", fmt.Sprintf("Query %d is created for %v, expiration: %s", id, sub.paths, expiration))
+	sub.id = id
+	sub.expiration = expiration
+	return nil
+}
+
+func (sub *Subscription) setErr(err error) {
+	sub.mutex.Lock()
+	sub.err = err
+	sub.mutex.Unlock()
+}
+
+func (sub *Subscription) run() {
+	defer close(sub.done)
+	defer close(sub.records)
+
+	period := time.Duration(sub.interval) * time.Second
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		// Metric will be empty if it is retrieved without waiting for at least a query interval after creating the query
+		select {
+		case <-sub.stop:
+			return
+		case <-ticker.C:
+		}
+
+		// Renew the query two intervals before its expiration
+		if !sub.expiration.IsZero() && time.Until(sub.expiration) < 2*period {
+			if err := sub.create(); err != nil {
+				sub.setErr(err)
+				continue
+			}
+		}
+
+		records, err := sub.poll()
+		sub.setErr(err)
+		if err != nil {
+			utils.Log("error", fmt.Sprintf("Fail to get the result of query %d due to %s", sub.id, err.Error()))
+		}
+		// The result of a deleted query is empty instead of an error, recreate the query once it is gone
+		if err != nil || len(records) == 0 {
+			if !sub.unity.MetricRealTimeQueryExisted(sub.id) {
+				sub.setErr(sub.create())
+			}
+			continue
+		}
+
+		select {
+		case sub.records <- records:
+		case <-sub.stop:
+			return
+		}
+	}
+}
+
+// Get records newer than the ones already delivered, the query result keeps several samples
+func (sub *Subscription) poll() ([]MetricRecord, error) {
+	var ret Metric
+	err := sub.unity.GetMetricQueryResult(sub.id, &ret)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []MetricRecord
+	latest := sub.latest
+	for _, record := range ret.Records() {
+		if !record.Timestamp.After(sub.latest) {
+			continue
+		}
+		records = append(records, record)
+		if record.Timestamp.After(latest) {
+			latest = record.Timestamp
+		}
+	}
+	sub.latest = latest
+	return records, nil
+}
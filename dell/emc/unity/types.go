@@ -5,7 +5,7 @@ import "time"
 type MetricRealTimeQuery struct {
 	Content struct {
 		Id             int       `json:"id"`
-		Paths          []string  `json:paths`
+		Paths          []string  `json:"paths"`
 		Interval       int       `json:"interval"`
 		MaximumSamples int       `json:"maximumSamples"`
 		Expiration     time.Time `json:"expiration"`
@@ -14,22 +14,33 @@ type MetricRealTimeQuery struct {
 
 type Metric struct {
 	Base    string    `json:"@base"`
-	Updated time.Time `json:updated`
+	Updated time.Time `json:"updated"`
 	Links   []struct {
 		Rel  string `json:"rel"`
 		Href string `json:"href"`
 	} `json:"links"`
-	Entries []struct {
-		Content struct {
-			QueryId   int       `json:"queryId"`
-			Path      string    `json:"path"`
-			Timestamp time.Time `json:"timestamp"`
-			Values    struct {
-				Spa interface{} `json:"spa"`
-				Spb interface{} `json:"spb"`
-				// Spa string `json:"spa"`
-				// Spb string `json:"spb"`
-			} `json:"values"`
-		} `json:"content"`
-	} `json:"entries"`
+	Entries []MetricEntry `json:"entries"`
+}
+
+type MetricEntry struct {
+	Content struct {
+		QueryId   int       `json:"queryId"`
+		Path      string    `json:"path"`
+		Timestamp time.Time `json:"timestamp"`
+		// Values Nested by each wildcard of the path, e.g. {"spa": {"sv_1": "123"}} for sp.*.storage.lun.*.reads,
+		// use Records to flatten them
+		Values interface{} `json:"values"`
+	} `json:"content"`
+}
+
+// MetricRecord A single metric value of an object
+type MetricRecord struct {
+	// Path Path with wildcards substituted, e.g. sp.spa.storage.lun.sv_1.reads
+	Path string `json:"path"`
+	// Metric Original path, e.g. sp.*.storage.lun.*.reads
+	Metric    string    `json:"metric"`
+	Value     float64   `json:"value"`
+	Timestamp time.Time `json:"timestamp"`
+	// Labels Object identifiers named by the path segment before each wildcard, e.g. {"sp": "spa", "lun": "sv_1"}
+	Labels map[string]string `json:"labels"`
 }
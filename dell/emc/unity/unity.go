@@ -1,72 +1,140 @@
 package unity
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/kckecheng/storagemetric/utils"
 	"net/http"
+	"time"
 )
 
 // Unity Unity array object
 type Unity struct {
 	server   string
+	port     string
 	username string
 	password string
 	token    string
 	client   http.Client
+	paging   PageOptions
+	ctx      context.Context
+	retry    utils.RetryPolicy
 }
 
 // New Init Unity Object
-func New(server string, username string, password string) (*Unity, error) {
-	if utils.Logger == nil {
-		utils.InitLogger("", "")
-	}
+func New(server string, username string, password string, opts ...Option) (*Unity, error) {
+	return NewWithContext(context.Background(), server, username, password, opts...)
+}
+
+// NewWithContext Init Unity Object, the login request is aborted once ctx is done
+func NewWithContext(ctx context.Context, server string, username string, password string, opts ...Option) (*Unity, error) {
+	o := newOptions(opts)
+	o.InitLogger()
 
 	var err error
-	utils.Log("debug", fmt.Sprintf("server: %s, username: %s, password: %s", server, username, password))
+	utils.Log("debug", fmt.Sprintf("server: %s, username: %s", server, username))
 	if utils.EmptyStrExists(server, username, password) == true {
 		return nil, errors.New("Unity server address, username, and password must all be specified")
 	}
+	utils.RegisterSecret(password)
+	connected := false
+	defer func() {
+		if !connected {
+			utils.UnregisterSecret(password)
+		}
+	}()
 
-	client := utils.InitHttpClient()
+	client, err := o.HttpClient()
+	if err != nil {
+		return nil, err
+	}
 
-	req, err := utils.InitHttpRequest("GET", utils.URL("https", server, "", "/api/types/loginSessionInfo/instances"), nil)
+	req, err := utils.InitHttpRequestWithContext(ctx, "GET", utils.URL("https", server, o.Port, "/api/types/loginSessionInfo/instances"), nil)
+	if err != nil {
+		return nil, err
+	}
 	req.SetBasicAuth(username, password)
 	req.Header.Set("X-EMC-REST-CLIENT", "true")
 
-	resp, err := utils.DoHttpRequest(&client, req)
+	resp, err := utils.DoHttpRequestWithRetry(&client, req, o.Retry)
 	if err != nil {
 		utils.Log("error", err.Error())
 		return nil, err
 	}
 
+	resp.Body.Close()
 	if resp.StatusCode != 200 {
-		message := fmt.Sprintf("Login %s with %s/%s failed.", server, username, password)
+		message := fmt.Sprintf("Login %s with %s failed with status code %d", server, username, resp.StatusCode)
 		utils.Log("error", message)
 		return nil, errors.New(message)
 	}
 
 	token := resp.Header.Get("Emc-Csrf-Token")
 
+	connected = true
 	return &Unity{
 		server:   server,
+		port:     o.Port,
 		username: username,
 		password: password,
 		token:    token,
 		client:   client,
+		retry:    o.Retry,
 	}, nil
 }
 
+// WithContext Get a copy of the object whose requests are all aborted once ctx is done
+func (unity *Unity) WithContext(ctx context.Context) *Unity {
+	clone := *unity
+	clone.ctx = ctx
+	return &clone
+}
+
+// SetTimeout Set the default timeout of each request, 0 means no timeout. It is not safe to call while
+// requests are being sent from other goroutines, set it right after initialization or use WithTimeout
+func (unity *Unity) SetTimeout(timeout time.Duration) {
+	unity.client.Timeout = timeout
+}
+
+// Context bound to the object, background context if none is bound
+func (unity *Unity) context() context.Context {
+	if unity.ctx == nil {
+		return context.Background()
+	}
+	return unity.ctx
+}
+
 // Request Send get/post/delete request
 func (unity *Unity) Request(method string, URI string, fields string, filter string, payload interface{}, result interface{}) error {
-	requestParams := fmt.Sprintf("method: %s, URI: %s, fields: %s, filter: %s, payload: %#v", method, URI, fields, filter, payload)
+	return unity.RequestWithContext(unity.context(), method, URI, fields, filter, payload, result)
+}
+
+// RequestWithContext Send get/post/delete request which is aborted once ctx is done
+func (unity *Unity) RequestWithContext(ctx context.Context, method string, URI string, fields string, filter string, payload interface{}, result interface{}) error {
+	params := map[string]string{}
+	if fields != "" {
+		params["fields"] = fields
+	}
+	if filter != "" {
+		params["filter"] = filter
+	}
+	return unity.request(ctx, method, URI, params, payload, result)
+}
+
+// Send a request with arbitrary query parameters
+func (unity *Unity) request(ctx context.Context, method string, URI string, params map[string]string, payload interface{}, result interface{}) error {
+	requestParams := fmt.Sprintf("method: %s, URI: %s, params: %v, payload: %#v", method, URI, params, payload)
 	utils.Log("debug", requestParams)
 	if utils.EmptyStrExists(method, URI) == true {
 		return errors.New("method, or URI is missed")
 	}
 
-	url := utils.URL("https", unity.server, "", URI)
-	req, err := utils.InitHttpRequest(method, url, payload)
+	url := utils.URL("https", unity.server, unity.port, URI)
+	req, err := utils.InitHttpRequestWithContext(ctx, method, url, payload)
+	if err != nil {
+		return err
+	}
 
 	// Add token in header if POST/DELETE
 	if method == "POST" || method == "DELETE" {
@@ -83,14 +151,9 @@ func (unity *Unity) Request(method string, URI string, fields string, filter str
 	if method != "DELETE" {
 		utils.UpdateHttpRequestParams(req, map[string]string{"compact": "true"})
 	}
-	if fields != "" {
-		utils.UpdateHttpRequestParams(req, map[string]string{"fields": fields})
-	}
-	if filter != "" {
-		utils.UpdateHttpRequestParams(req, map[string]string{"filter": filter})
-	}
+	utils.UpdateHttpRequestParams(req, params)
 
-	resp, err := utils.DoHttpRequest(&unity.client, req)
+	resp, err := utils.DoHttpRequestWithRetry(&unity.client, req, unity.retry)
 	if err != nil {
 		utils.Log("error", err.Error())
 		return err
@@ -103,8 +166,10 @@ func (unity *Unity) Request(method string, URI string, fields string, filter str
 			return err
 		}
 		// Do not need to capture the response
+		resp.Body.Close()
 		return nil
 	}
+	resp.Body.Close()
 	return errors.New(fmt.Sprintf("Failed request with status code %d", resp.StatusCode))
 }
 
@@ -112,5 +177,6 @@ func (unity *Unity) Request(method string, URI string, fields string, filter str
 func (unity *Unity) Destroy() error {
 	utils.Log("debug", "Logout")
 	err := unity.Request("POST", "/api/types/loginSessionInfo/action/logout", "", "", nil, nil)
+	utils.UnregisterSecret(unity.password)
 	return err
 }
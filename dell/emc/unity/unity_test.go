@@ -1,16 +1,30 @@
 package unity
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/kckecheng/storagemetric/storage"
+	"github.com/kckecheng/storagemetric/utils"
+	"github.com/sirupsen/logrus"
 )
 
 var server = flag.String("server", "", "Unity IP/FQDN")
 var username = flag.String("username", "admin", "Unity user name")
 var password = flag.String("password", "", "Unity user password")
 var interval = flag.Int("interval", 10, "Interval in seconds to collect metric")
+var insecure = flag.Bool("insecure", false, "Skip the verification of the server certificate")
+var cacert = flag.String("cacert", "", "CA bundle to verify the server certificate")
 
 func FailIfError(t *testing.T, err error) {
 	if err != nil {
@@ -26,7 +40,7 @@ func TestUnity(t *testing.T) {
 	}
 
 	var err error
-	unityBox, err := New(*server, *username, *password)
+	unityBox, err := New(*server, *username, *password, WithTLS(utils.TLSOptions{Insecure: *insecure, CAFile: *cacert}))
 	FailIfError(t, err)
 
 	var pathSet [2][]string
@@ -81,3 +95,499 @@ func TestUnity(t *testing.T) {
 	err = unityBox.Destroy()
 	FailIfError(t, err)
 }
+
+// Login a stand-in Unity whose other requests are handled by handler
+func fakeUnity(t *testing.T, handler http.HandlerFunc) (*Unity, func()) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/types/loginSessionInfo/instances" {
+			w.Header().Set("EMC-CSRF-TOKEN", "token")
+			w.Write([]byte(`{"entries": []}`))
+			return
+		}
+		handler(w, r)
+	}))
+
+	unityBox, err := New(strings.TrimPrefix(ts.URL, "https://"), "admin", "password", WithInsecure())
+	if err != nil {
+		ts.Close()
+		t.Fatal(err)
+	}
+	return unityBox, ts.Close
+}
+
+func TestMetricCatalog(t *testing.T) {
+	unityBox, done := fakeUnity(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/types/metric/instances" || !strings.Contains(r.URL.Query().Get("fields"), "isRealtimeAvailable") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"entries": [
+			{"content": {"id": 10, "path": "sp.*.storage.lun.*.reads", "type": 3, "description": "Reads of a LUN", "isHistoricalAvailable": false, "isRealtimeAvailable": true, "unitDisplayString": "I/O", "visibility": 2}},
+			{"content": {"id": 20, "path": "sp.*.cpu.summary.utilization", "type": 5, "description": "CPU Utilization", "isHistoricalAvailable": true, "isRealtimeAvailable": false, "unitDisplayString": "%", "visibility": 2}}
+		]}`))
+	})
+	defer done()
+
+	catalog, err := unityBox.GetMetricCatalog()
+	FailIfError(t, err)
+
+	m, ok := catalog.Lookup("sp.spa.storage.lun.sv_1.reads")
+	if !ok || !m.IsCounter() || m.TypeName() != "counter64" {
+		t.Errorf("Unexpected lookup result %+v", m)
+	}
+	if _, ok := catalog.Lookup("sp.spa.storage.lun.reads"); ok {
+		t.Error("Path with a different depth should not match")
+	}
+	if ret := catalog.Search("cpu"); len(ret) != 1 || ret[0].Id != 20 {
+		t.Errorf("Unexpected search result %+v", ret)
+	}
+	FailIfError(t, catalog.Validate([]string{"sp.*.storage.lun.*.reads"}, true))
+	if err := catalog.Validate([]string{"sp.*.cpu.summary.utilization"}, true); err == nil {
+		t.Error("Historical only path should not be valid for real time queries")
+	}
+	if err := catalog.Validate([]string{"sp.*.cpu.summary.busyTicks"}, false); err == nil {
+		t.Error("Unknown path should not be valid")
+	}
+}
+
+func TestMetricRecords(t *testing.T) {
+	var ret Metric
+	err := json.Unmarshal([]byte(`{"entries": [
+		{"content": {"path": "sp.*.storage.lun.*.reads", "timestamp": "2020-01-01T00:01:00.000Z", "values": {"spa": {"sv_1": "100", "sv_2": 200}, "spb": {"sv_1": "7"}}}},
+		{"content": {"path": "sp.*.cpu.summary.busyTicks", "timestamp": "2020-01-01T00:00:00.000Z", "values": {"spa": "3000", "spb": "n/a"}}}
+	]}`), &ret)
+	FailIfError(t, err)
+
+	records := ret.Records()
+	if len(records) != 4 {
+		t.Fatalf("Unexpected records %+v", records)
+	}
+	if records[0].Path != "sp.spa.cpu.summary.busyTicks" || records[0].Value != 3000 || records[0].Labels["sp"] != "spa" {
+		t.Errorf("Unexpected record %+v", records[0])
+	}
+	last := records[3]
+	if last.Path != "sp.spb.storage.lun.sv_1.reads" || last.Metric != "sp.*.storage.lun.*.reads" || last.Value != 7 || last.Labels["sp"] != "spb" || last.Labels["lun"] != "sv_1" {
+		t.Errorf("Unexpected record %+v", last)
+	}
+}
+
+func TestRateCalculator(t *testing.T) {
+	t0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	record := func(metric string, sp string, value float64, offset int) MetricRecord {
+		return MetricRecord{
+			Path:      strings.Replace(metric, "*", sp, 1),
+			Metric:    metric,
+			Value:     value,
+			Timestamp: t0.Add(time.Duration(offset) * time.Second),
+			Labels:    map[string]string{"sp": sp},
+		}
+	}
+	busy, idle := "sp.*.cpu.summary.busyTicks", "sp.*.cpu.summary.idleTicks"
+
+	rc := NewRateCalculator()
+	if rates := rc.Rates([]MetricRecord{record(busy, "spa", 1000, 0), record(idle, "spa", 5000, 0)}); len(rates) != 0 {
+		t.Errorf("First records should only set the baseline, got %+v", rates)
+	}
+
+	rates := rc.Rates([]MetricRecord{record(busy, "spa", 1600, 60), record(idle, "spa", 6800, 60)})
+	if len(rates) != 2 || rates[0].Value != 10 || rates[1].Value != 30 {
+		t.Fatalf("Unexpected rates %+v", rates)
+	}
+	util := CPUUtilization(rates)
+	if len(util) != 1 || util[0].Value != 25 || util[0].Path != "sp.spa.cpu.summary.utilization" {
+		t.Errorf("Unexpected utilization %+v", util)
+	}
+
+	// SP reboot resets the counters
+	if rates := rc.Rates([]MetricRecord{record(busy, "spa", 20, 120)}); len(rates) != 0 {
+		t.Errorf("Counter reset should not produce a rate, got %+v", rates)
+	}
+	if rates := rc.Rates([]MetricRecord{record(busy, "spa", 80, 180)}); len(rates) != 1 || rates[0].Value != 1 {
+		t.Errorf("Unexpected rates after reset %+v", rates)
+	}
+}
+
+func TestSubscription(t *testing.T) {
+	var mutex sync.Mutex
+	queries := map[int]bool{}
+	nextId := 1
+	unityBox, done := fakeUnity(t, func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		var id int
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/api/types/metricRealTimeQuery/instances":
+			queries[nextId] = true
+			fmt.Fprintf(w, `{"content": {"id": %d}}`, nextId)
+			nextId++
+		case r.Method == "GET" && r.URL.Path == "/api/types/metricQueryResult/instances":
+			fmt.Sscanf(r.URL.Query().Get("filter"), "queryId eq %d", &id)
+			// Unity returns an empty result for a deleted query
+			if !queries[id] {
+				w.Write([]byte(`{"entries": []}`))
+				return
+			}
+			fmt.Fprintf(w, `{"entries": [{"content": {"queryId": %d, "path": "sp.*.cpu.summary.busyTicks", "timestamp": "%s", "values": {"spa": "%d"}}}]}`,
+				id, time.Now().UTC().Format(time.RFC3339), id)
+		case strings.HasPrefix(r.URL.Path, "/api/instances/metricRealTimeQuery/"):
+			fmt.Sscanf(r.URL.Path, "/api/instances/metricRealTimeQuery/%d", &id)
+			if !queries[id] {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if r.Method == "DELETE" {
+				delete(queries, id)
+				return
+			}
+			fmt.Fprintf(w, `{"content": {"id": %d, "expiration": "%s"}}`, id, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer done()
+
+	sub, err := unityBox.Subscribe([]string{"sp.*.cpu.summary.busyTicks"}, 1)
+	FailIfError(t, err)
+
+	records := <-sub.C
+	if len(records) != 1 || records[0].Value != 1 {
+		t.Fatalf("Unexpected records %+v", records)
+	}
+
+	// The query disappears, e.g. expired on the array
+	mutex.Lock()
+	delete(queries, 1)
+	mutex.Unlock()
+	for records = range sub.C {
+		if records[0].Value == 2 {
+			break
+		}
+	}
+
+	FailIfError(t, sub.Close())
+	if _, ok := <-sub.C; ok {
+		t.Error("Channel should be closed")
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(queries) != 0 {
+		t.Errorf("Queries are leaked: %v", queries)
+	}
+}
+
+func TestCleanupMetricRealTimeQueries(t *testing.T) {
+	var deleted []string
+	unityBox, done := fakeUnity(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/api/types/metricRealTimeQuery/instances":
+			w.Write([]byte(`{"entries": [
+				{"content": {"id": 1, "paths": ["sp.*.cpu.summary.busyTicks", "sp.*.cpu.summary.idleTicks"], "interval": 60, "expiration": "2020-01-01T01:00:00.000Z"}},
+				{"content": {"id": 2, "paths": ["sp.*.cpu.summary.idleTicks", "sp.*.cpu.summary.busyTicks"], "interval": 60, "expiration": "2020-01-01T03:00:00.000Z"}},
+				{"content": {"id": 3, "paths": ["sp.*.cpu.summary.busyTicks"], "interval": 60, "expiration": "2020-01-01T01:00:00.000Z"}}
+			]}`))
+		case r.Method == "DELETE":
+			deleted = append(deleted, r.URL.Path)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer done()
+
+	ret, err := unityBox.CleanupMetricRealTimeQueries(QueryCleanupFilter{
+		Paths:         []string{"sp.*.cpu.summary.busyTicks", "sp.*.cpu.summary.idleTicks"},
+		ExpiresBefore: time.Date(2020, 1, 1, 2, 0, 0, 0, time.UTC),
+	})
+	FailIfError(t, err)
+	if len(ret) != 1 || ret[0].Content.Id != 1 || len(deleted) != 1 || deleted[0] != "/api/instances/metricRealTimeQuery/1" {
+		t.Errorf("Unexpected deleted queries %+v %v", ret, deleted)
+	}
+}
+
+func TestPagination(t *testing.T) {
+	unityBox, done := fakeUnity(t, func(w http.ResponseWriter, r *http.Request) {
+		var page, perPage int
+		fmt.Sscanf(r.URL.Query().Get("page"), "%d", &page)
+		fmt.Sscanf(r.URL.Query().Get("per_page"), "%d", &perPage)
+		if perPage == 0 {
+			perPage = 2
+		}
+
+		var entries []string
+		for i := (page-1)*perPage + 1; i <= page*perPage && i <= 5; i++ {
+			entries = append(entries, fmt.Sprintf(`{"content": {"path": "sp.*.cpu.summary.utilization", "timestamp": "2020-01-01T00:%02d:00.000Z", "values": {"spa": %d}}}`, i, i))
+		}
+		links := fmt.Sprintf(`{"rel": "self", "href": "&page=%d"}`, page)
+		if page*perPage < 5 {
+			links += fmt.Sprintf(`, {"rel": "next", "href": "&page=%d"}`, page+1)
+		}
+		fmt.Fprintf(w, `{"@base": "https://unity/api/types/metricValue/instances", "updated": "2020-01-01T00:%02d:30.000Z", "entries": [%s], "links": [%s]}`, page, strings.Join(entries, ","), links)
+	})
+	defer done()
+
+	var ret Metric
+	FailIfError(t, unityBox.GetHistoricalMetric("sp.*.cpu.summary.utilization", &ret))
+	if len(ret.Entries) != 5 {
+		t.Errorf("Expected 5 entries, got %d", len(ret.Entries))
+	}
+	if ret.Base != "https://unity/api/types/metricValue/instances" || !ret.Updated.Equal(time.Date(2020, 1, 1, 0, 1, 30, 0, time.UTC)) ||
+		len(ret.Links) != 2 || ret.Links[1].Href != "&page=2" {
+		t.Errorf("Unexpected envelope of the first page %+v", ret)
+	}
+
+	it := unityBox.NewIterator("/api/types/metricValue/instances", "", "", PageOptions{PerPage: 1, MaxItems: 3})
+	var values []float64
+	for it.Next() {
+		var entry MetricEntry
+		FailIfError(t, it.Decode(&entry))
+		values = append(values, entry.Content.Values.(map[string]interface{})["spa"].(float64))
+	}
+	FailIfError(t, it.Err())
+	if len(values) != 3 || values[2] != 3 {
+		t.Errorf("Unexpected values %v", values)
+	}
+}
+
+func TestGetHistoricalMetricRange(t *testing.T) {
+	var filter string
+	unityBox, done := fakeUnity(t, func(w http.ResponseWriter, r *http.Request) {
+		filter = r.URL.Query().Get("filter")
+		w.Write([]byte(`{"entries": [
+			{"content": {"path": "sp.*.cpu.summary.utilization", "interval": 300, "timestamp": "2020-01-01T00:10:00.000Z", "values": {"spa": 20}}},
+			{"content": {"path": "sp.*.cpu.summary.utilization", "interval": 300, "timestamp": "2020-01-01T00:05:00.000Z", "values": {"spa": 10}}}
+		]}`))
+	})
+	defer done()
+
+	from := time.Date(2020, 1, 1, 0, 5, 0, 0, time.UTC)
+	to := time.Date(2020, 1, 1, 0, 10, 0, 0, time.UTC)
+	records, err := unityBox.GetHistoricalMetricRange("sp.*.cpu.summary.utilization", from, to, HistoricalInterval5Min)
+	FailIfError(t, err)
+
+	expected := `path eq "sp.*.cpu.summary.utilization" and interval eq 300 and timestamp gt "2020-01-01T00:04:59.999Z" and timestamp lt "2020-01-01T00:10:00.001Z"`
+	if filter != expected {
+		t.Errorf("Unexpected filter %s", filter)
+	}
+	if len(records) != 2 || records[0].Value != 10 || records[1].Value != 20 {
+		t.Errorf("Unexpected records %+v", records)
+	}
+	if _, err := unityBox.GetHistoricalMetricRange("sp.*.cpu.summary.utilization", from, to, 120); err == nil {
+		t.Error("Expected an error for unsupported interval")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	ts := time.Date(2020, 1, 1, 8, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	cases := map[string]Filter{
+		`path eq "sp.*.cpu.summary.utilization"`:                             Eq("path", "sp.*.cpu.summary.utilization"),
+		`name lk "say \"hi\" \\ %"`:                                          Lk("name", `say "hi" \ %`),
+		`queryId eq 12 and timestamp gt "2020-01-01T00:00:00.000Z"`:          And(Eq("queryId", 12), Gt("timestamp", ts)),
+		`type eq 2 or type ne 3`:                                             Or(Eq("type", 2), Ne("type", 3), Filter{}),
+		`isThinEnabled eq true and (sizeTotal lt 100 or sizeTotal gt 200.5)`: And(Eq("isThinEnabled", true), Or(Lt("sizeTotal", 100), Gt("sizeTotal", 200.5))),
+		`(a eq 1 and b eq 2) or c eq 3`:                                      Or(And(Eq("a", 1), Eq("b", 2)), Eq("c", 3)),
+		`a eq 1 and b eq 2 and c eq 3`:                                       And(And(Eq("a", 1), Eq("b", 2)), Eq("c", 3)),
+		`(a eq 1)`:                                                           Group(Eq("a", 1)),
+	}
+	for expected, f := range cases {
+		if f.String() != expected {
+			t.Errorf("Expected %s, got %s", expected, f.String())
+		}
+	}
+
+	if fields := Fields("id", " name ", "", "id", "pool.name"); fields != "id,name,pool.name" {
+		t.Errorf("Unexpected fields %s", fields)
+	}
+}
+
+func TestInventory(t *testing.T) {
+	unityBox, done := fakeUnity(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/types/lun/instances":
+			if !strings.Contains(r.URL.Query().Get("fields"), "sizeAllocated") {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"entries": [
+				{"content": {"id": "sv_1", "name": "oradata", "sizeTotal": 107374182400, "sizeAllocated": 53687091200, "pool": {"id": "pool_1"}, "health": {"value": 5, "descriptionIds": ["ALRT_VOL_OK"]}}},
+				{"content": {"id": "sv_2", "name": "oralog", "sizeTotal": 10737418240, "pool": {"id": "pool_1"}, "health": {"value": 20}}}
+			]}`))
+		case "/api/instances/pool/pool_1":
+			w.Write([]byte(`{"content": {"id": "pool_1", "name": "Pool 1", "sizeTotal": 1099511627776, "sizeUsed": 549755813888, "dataReductionRatio": 2.5}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer done()
+
+	luns, err := unityBox.GetLUNs()
+	FailIfError(t, err)
+	if len(luns) != 2 || luns[0].Name != "oradata" || luns[0].Pool.Id != "pool_1" || luns[0].SizeAllocated != 53687091200 ||
+		luns[0].Health.String() != "OK" || luns[1].Health.String() != "MAJOR" {
+		t.Errorf("Unexpected LUNs %+v", luns)
+	}
+
+	pool, err := unityBox.GetPool("pool_1")
+	FailIfError(t, err)
+	if pool.Name != "Pool 1" || pool.SizeUsed != 549755813888 || pool.DataReductionRatio != 2.5 {
+		t.Errorf("Unexpected pool %+v", pool)
+	}
+
+	if _, err := unityBox.GetDisk("dpe_disk_0"); err == nil {
+		t.Error("Expected an error for a missing disk")
+	}
+}
+
+func TestPoolDaysUntilFull(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var samples []storage.Sample
+	for day := 0; day < 3; day++ {
+		ts := start.AddDate(0, 0, day)
+		samples = append(samples,
+			storage.Sample{Kind: storage.KindPool, ID: "pool_1", Metric: "sizeTotal", Timestamp: ts, Value: 100},
+			storage.Sample{Kind: storage.KindPool, ID: "pool_1", Metric: "sizeUsed", Timestamp: ts, Value: float64(50 + 10*day)},
+			storage.Sample{Kind: storage.KindLUN, ID: "sv_1", Metric: "sizeUsed", Timestamp: ts, Value: 1},
+		)
+	}
+
+	days := PoolDaysUntilFull(samples)
+	if len(days) != 1 || days["pool_1"] != 3 {
+		t.Errorf("Unexpected days until full %v", days)
+	}
+}
+
+func TestContext(t *testing.T) {
+	unityBox, done := fakeUnity(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	defer done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	_, err := unityBox.WithContext(ctx).GetPools()
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expect canceled, got %v", err)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/types/loginSessionInfo/instances":
+			w.Header().Set("EMC-CSRF-TOKEN", "token")
+			w.Write([]byte(`{"entries": []}`))
+		case "/api/types/system/instances":
+			w.Write([]byte(`{"entries": [{"content": {"id": "0", "name": "unity01"}}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	addr := strings.Split(strings.TrimPrefix(ts.URL, "https://"), ":")
+	unityBox, err := New(addr[0], "admin", "password", WithPort(addr[1]), WithHTTPClient(ts.Client()), WithTimeout(time.Second))
+	FailIfError(t, err)
+	system, err := unityBox.GetSystem()
+	FailIfError(t, err)
+	if system.Name != "unity01" {
+		t.Errorf("Unexpected system %+v", system)
+	}
+}
+
+func TestNoSecretLogged(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetLevel(logrus.TraceLevel)
+	defer func(previous *logrus.Logger) { utils.Logger = previous }(utils.Logger)
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Later requests are authenticated by the session cookie
+		_, password, ok := r.BasicAuth()
+		if ok && password != "Unity-Pa55" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("EMC-CSRF-TOKEN", "csrf-4a7b9c")
+		w.Write([]byte(`{"entries": []}`))
+	}))
+	defer ts.Close()
+	addr := strings.TrimPrefix(ts.URL, "https://")
+
+	_, err := New(addr, "admin", "Wr0ng-Pa55", WithInsecure(), WithLogger(logger))
+	if err == nil || strings.Contains(err.Error(), "Wr0ng-Pa55") {
+		t.Errorf("Unexpected login error %v", err)
+	}
+
+	unityBox, err := New(addr, "admin", "Unity-Pa55", WithInsecure(), WithLogger(logger))
+	FailIfError(t, err)
+	FailIfError(t, unityBox.Destroy())
+
+	output := buf.String()
+	for _, secret := range []string{"Wr0ng-Pa55", "Unity-Pa55", "csrf-4a7b9c"} {
+		if strings.Contains(output, secret) {
+			t.Errorf("Secret %s is found in the log:\n%s", secret, output)
+		}
+	}
+}
+
+func TestCollector(t *testing.T) {
+	var filter string
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/types/loginSessionInfo/instances":
+			w.Header().Set("EMC-CSRF-TOKEN", "token")
+			w.Write([]byte(`{"entries": []}`))
+		case "/api/types/system/instances":
+			w.Write([]byte(`{"entries": [{"content": {"id": "0", "name": "unity01", "serialNumber": "CKM00000000001"}}]}`))
+		case "/api/types/storageProcessor/instances":
+			w.Write([]byte(`{"entries": [{"content": {"id": "spa", "name": "SP A"}}, {"content": {"id": "spb", "name": "SP B"}}]}`))
+		case "/api/types/metricValue/instances":
+			filter = r.URL.Query().Get("filter")
+			w.Write([]byte(`{"entries": [
+				{"content": {"path": "sp.*.cpu.summary.utilization", "interval": 300, "timestamp": "2020-01-01T00:05:00.000Z", "values": {"spa": 10, "spb": 30}}}
+			]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c := NewCollector(strings.TrimPrefix(ts.URL, "https://"), "admin", "password", "sp.*.cpu.summary.utilization").WithOptions(WithInsecure())
+	if _, err := c.Resources(); err == nil {
+		t.Error("Expect an error before connecting")
+	}
+	FailIfError(t, c.Connect())
+	defer c.Close()
+
+	resources, err := c.Resources()
+	FailIfError(t, err)
+	if len(resources) != 3 || resources[0] != (storage.Resource{Kind: storage.KindArray, ID: "CKM00000000001", Name: "CKM00000000001"}) ||
+		resources[1] != (storage.Resource{Kind: storage.KindStorageProcessor, ID: "spa", Name: "SP A"}) {
+		t.Errorf("Unexpected resources %+v", resources)
+	}
+
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	samples, err := c.Collect(from, from.Add(10*time.Minute))
+	FailIfError(t, err)
+	expected := `path eq "sp.*.cpu.summary.utilization" and interval eq 300 and timestamp gt "2019-12-31T23:59:59.999Z" and timestamp lt "2020-01-01T00:10:00.001Z"`
+	if filter != expected {
+		t.Errorf("Unexpected filter %s", filter)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("Unexpected samples %+v", samples)
+	}
+	for _, s := range samples {
+		if s.Kind != storage.KindStorageProcessor || s.Unit != "%" || s.Labels["array"] != "CKM00000000001" || s.Labels["sp"] != s.ID {
+			t.Errorf("Unexpected sample %+v", s)
+		}
+		if (s.ID == "spa" && s.Value != 10) || (s.ID == "spb" && s.Value != 30) {
+			t.Errorf("Unexpected value of %s: %v", s.ID, s.Value)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// Resource kinds shared by all collectors
+const (
+	KindArray            = "array"
+	KindStorageGroup     = "storagegroup"
+	KindStorageProcessor = "sp"
+	KindPool             = "pool"
+	KindLUN              = "lun"
+	KindFilesystem       = "filesystem"
+)
+
+// Resource A collectable object on an array, such as the array itself, a storage group or a SP
+type Resource struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Sample A single vendor neutral metric value
+type Sample struct {
+	Kind      string            `json:"kind"`
+	ID        string            `json:"id"`
+	Metric    string            `json:"metric"`
+	Unit      string            `json:"unit"`
+	Timestamp time.Time         `json:"timestamp"`
+	Value     float64           `json:"value"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// Collector Common interface implemented by all array clients
+type Collector interface {
+	// Connect Login the array and validate the provided information
+	Connect() error
+	// Resources List resources metrics can be collected for
+	Resources() ([]Resource, error)
+	// Collect Collect samples within the time range [from, to]
+	Collect(from time.Time, to time.Time) ([]Sample, error)
+	// Close Release the connection to the array
+	Close() error
+}
+
+// ContextCollector Collector whose collection can be aborted through a context
+type ContextCollector interface {
+	Collector
+	// CollectContext Collect samples within the time range [from, to], abort once ctx is done
+	CollectContext(ctx context.Context, from time.Time, to time.Time) ([]Sample, error)
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// LinearFit Fit value = slope * seconds + intercept on samples by least squares,
+// seconds are counted from the earliest sample
+func LinearFit(samples []Sample) (slope float64, intercept float64, err error) {
+	if len(samples) < 2 {
+		return 0, 0, errors.New("at least 2 samples are required")
+	}
+
+	sorted := append([]Sample(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
+	start := sorted[0].Timestamp
+
+	var sumX, sumY, sumXY, sumXX float64
+	n := float64(len(sorted))
+	for _, s := range sorted {
+		x := s.Timestamp.Sub(start).Seconds()
+		sumX += x
+		sumY += s.Value
+		sumXY += x * s.Value
+		sumXX += x * x
+	}
+
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, 0, errors.New("samples must span more than one timestamp")
+	}
+	slope = (n*sumXY - sumX*sumY) / denominator
+	intercept = (sumY - slope*sumX) / n
+	return slope, intercept, nil
+}
+
+// DaysUntil Estimate the days from the latest sample until the fitted trend reaches limit,
+// +Inf is returned if the trend is flat or decreasing, 0 if the limit has been reached
+func DaysUntil(samples []Sample, limit float64) (float64, error) {
+	slope, intercept, err := LinearFit(samples)
+	if err != nil {
+		return 0, err
+	}
+
+	start, latest := samples[0].Timestamp, samples[0].Timestamp
+	for _, s := range samples {
+		if s.Timestamp.Before(start) {
+			start = s.Timestamp
+		}
+		if s.Timestamp.After(latest) {
+			latest = s.Timestamp
+		}
+	}
+
+	if slope <= 0 {
+		return math.Inf(1), nil
+	}
+	seconds := (limit-intercept)/slope - latest.Sub(start).Seconds()
+	if seconds < 0 {
+		return 0, nil
+	}
+	return seconds / 86400, nil
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDaysUntil(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var samples []Sample
+	// 10 GB used growing by 1 GB a day
+	for day := 0; day < 5; day++ {
+		samples = append(samples, Sample{Timestamp: start.AddDate(0, 0, day), Value: float64(10+day) * 1e9})
+	}
+
+	days, err := DaysUntil(samples, 100e9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(days-86) > 1e-6 {
+		t.Errorf("Expected 86 days, got %f", days)
+	}
+
+	if days, _ := DaysUntil(samples, 5e9); days != 0 {
+		t.Errorf("Expected 0 days for a reached limit, got %f", days)
+	}
+	for i := range samples {
+		samples[i].Value = 10e9
+	}
+	if days, _ := DaysUntil(samples, 100e9); !math.IsInf(days, 1) {
+		t.Errorf("Expected +Inf for flat usage, got %f", days)
+	}
+	if _, err := DaysUntil(samples[:1], 100e9); err == nil {
+		t.Error("Expected an error for a single sample")
+	}
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// Supported aggregation methods
+const (
+	AggAvg  = "avg"
+	AggMin  = "min"
+	AggMax  = "max"
+	AggP95  = "p95"
+	AggLast = "last"
+)
+
+var aggMethods = map[string]bool{AggAvg: true, AggMin: true, AggMax: true, AggP95: true, AggLast: true}
+
+// AggregateValues Aggregate values with the specified method, 0 is returned for empty values
+func AggregateValues(values []float64, method string) (float64, error) {
+	if !aggMethods[method] {
+		return 0, fmt.Errorf("unsupported aggregation method %s", method)
+	}
+	if len(values) == 0 {
+		return 0, nil
+	}
+
+	switch method {
+	case AggAvg:
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values)), nil
+	case AggMin:
+		min := values[0]
+		for _, v := range values[1:] {
+			min = math.Min(min, v)
+		}
+		return min, nil
+	case AggMax:
+		max := values[0]
+		for _, v := range values[1:] {
+			max = math.Max(max, v)
+		}
+		return max, nil
+	case AggP95:
+		// Nearest rank percentile
+		sorted := append([]float64(nil), values...)
+		sort.Float64s(sorted)
+		rank := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+		return sorted[rank], nil
+	case AggLast:
+		return values[len(values)-1], nil
+	}
+	return 0, fmt.Errorf("unsupported aggregation method %s", method)
+}
+
+// Aggregate Aggregate a slice of metric structs into result, which must be a pointer to the element type
+// float64 fields are aggregated with the specified method, int64 fields (timestamps) take the max value,
+// and other fields are copied from the last element
+func Aggregate(series interface{}, method string, result interface{}) error {
+	sv := reflect.ValueOf(series)
+	if sv.Kind() != reflect.Slice {
+		return errors.New("series must be a slice")
+	}
+	rv := reflect.ValueOf(result)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Type() != sv.Type().Elem() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("result must be a pointer to the struct type of series elements")
+	}
+
+	if !aggMethods[method] {
+		return fmt.Errorf("unsupported aggregation method %s", method)
+	}
+
+	out := rv.Elem()
+	out.Set(reflect.Zero(out.Type()))
+	n := sv.Len()
+	if n == 0 {
+		return nil
+	}
+	out.Set(sv.Index(n - 1))
+
+	values := make([]float64, n)
+	for i := 0; i < out.NumField(); i++ {
+		field := out.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+
+		switch field.Kind() {
+		case reflect.Float64:
+			for j := 0; j < n; j++ {
+				values[j] = sv.Index(j).Field(i).Float()
+			}
+			v, err := AggregateValues(values, method)
+			if err != nil {
+				return err
+			}
+			field.SetFloat(v)
+		case reflect.Int64:
+			max := sv.Index(0).Field(i).Int()
+			for j := 1; j < n; j++ {
+				if v := sv.Index(j).Field(i).Int(); v > max {
+					max = v
+				}
+			}
+			field.SetInt(max)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package utils
+
+import "testing"
+
+type testMetric struct {
+	IOs       float64
+	Name      string
+	Timestamp int64
+}
+
+func TestAggregate(t *testing.T) {
+	series := []testMetric{
+		{IOs: 10, Name: "a", Timestamp: 3000},
+		{IOs: 40, Name: "b", Timestamp: 1000},
+		{IOs: 20, Name: "c", Timestamp: 2000},
+		{IOs: 30, Name: "d", Timestamp: 4000},
+	}
+
+	expected := map[string]float64{AggAvg: 25, AggMin: 10, AggMax: 40, AggP95: 40, AggLast: 30}
+	for method, value := range expected {
+		var ret testMetric
+		if err := Aggregate(series, method, &ret); err != nil {
+			t.Fatal(err)
+		}
+		if ret.IOs != value || ret.Timestamp != 4000 || ret.Name != "d" {
+			t.Errorf("%s: unexpected result %+v", method, ret)
+		}
+	}
+
+	var ret testMetric
+	if err := Aggregate(series, "median", &ret); err == nil {
+		t.Error("Expected an error for unsupported method")
+	}
+	if err := Aggregate(series, AggAvg, ret); err == nil {
+		t.Error("Expected an error for non pointer result")
+	}
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/cookiejar"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ClientOptions Optional settings shared by the array clients, set through the Option wrappers of each client
+type ClientOptions struct {
+	TLS       TLSOptions
+	TLSConfig *tls.Config
+	Client    *http.Client
+	Timeout   *time.Duration
+	Logger    *log.Logger
+	Port      string
+	Retry     RetryPolicy
+}
+
+// HttpClient Build the HTTP client based on the options
+func (o ClientOptions) HttpClient() (http.Client, error) {
+	var client http.Client
+	switch {
+	case o.Client != nil:
+		client = *o.Client
+		if client.Jar == nil {
+			client.Jar, _ = cookiejar.New(nil)
+		}
+	case o.TLSConfig != nil:
+		client = InitHttpClientWithTLSConfig(o.TLSConfig)
+	default:
+		var err error
+		client, err = InitHttpClientWithTLS(o.TLS)
+		if err != nil {
+			return client, err
+		}
+	}
+
+	if o.Timeout != nil {
+		client.Timeout = *o.Timeout
+	}
+	return client, nil
+}
+
+// Whether the process wide logger has been set by a client
+var clientLogger sync.Once
+
+// InitLogger Set the process wide logger to the logger of the options unless another client has set its logger,
+// or init the global logger if it is not initialized yet
+func (o ClientOptions) InitLogger() {
+	if o.Logger == nil {
+		if Logger == nil {
+			InitLogger("", "")
+		}
+		return
+	}
+
+	clientLogger.Do(func() { Logger = o.Logger })
+	if Logger != o.Logger {
+		Log("warning", "The logger is ignored since the process wide logger has been set by another client")
+	}
+}
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestClientLogger(t *testing.T) {
+	defer func(previous *log.Logger) { Logger = previous }(Logger)
+
+	first, second := log.New(), log.New()
+	ClientOptions{Logger: first}.InitLogger()
+	if Logger != first {
+		t.Error("The logger of the first client should be used")
+	}
+
+	// Another client must not replace the logger in use
+	ClientOptions{Logger: second}.InitLogger()
+	ClientOptions{}.InitLogger()
+	if Logger != first {
+		t.Error("The logger of the first client should not be replaced")
+	}
+}
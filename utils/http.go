@@ -2,26 +2,31 @@ package utils
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"net/http/cookiejar"
 	"net/http/httputil"
+	"time"
 )
 
+// DefaultHttpTimeout Default timeout of a whole request including reading the response body
+const DefaultHttpTimeout = 60 * time.Second
+
+// InitHttpClient Init a HTTP client verifying server certificates against the system CA pool
 func InitHttpClient() http.Client {
-	cookieJar, _ := cookiejar.New(nil)
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	client := http.Client{Transport: tr, Jar: cookieJar}
+	client, _ := InitHttpClientWithTLS(TLSOptions{})
 	return client
 }
 
 func InitHttpRequest(method string, url string, payload interface{}) (*http.Request, error) {
+	return InitHttpRequestWithContext(context.Background(), method, url, payload)
+}
+
+// InitHttpRequestWithContext Init a request which is aborted once ctx is done
+func InitHttpRequestWithContext(ctx context.Context, method string, url string, payload interface{}) (*http.Request, error) {
 	requestInfo := fmt.Sprintf("method: %s, url: %s", method, url)
 	Log("debug", requestInfo)
 
@@ -30,13 +35,14 @@ func InitHttpRequest(method string, url string, payload interface{}) (*http.Requ
 	}
 
 	var req *http.Request
+	var err error
 	if payload != nil {
 		payloadJSON, _ := json.Marshal(payload)
-		req, _ = http.NewRequest(method, url, bytes.NewBuffer(payloadJSON))
+		req, err = http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(payloadJSON))
 	} else {
-		req, _ = http.NewRequest(method, url, nil)
+		req, err = http.NewRequestWithContext(ctx, method, url, nil)
 	}
-	return req, nil
+	return req, err
 }
 
 func UpdateHttpRequestHeaders(req *http.Request, headers map[string]string) {
@@ -56,13 +62,22 @@ func UpdateHttpRequestParams(req *http.Request, pairs map[string]string) {
 }
 
 func DoHttpRequest(client *http.Client, req *http.Request) (*http.Response, error) {
-	reqDetails, _ := httputil.DumpRequest(req, true)
-	Log("debug", fmt.Sprintf("Request Details:\n%s", string(reqDetails)))
+	// Dumping reads and buffers the whole body, only do it when the dump is logged
+	dump := LogEnabled("debug")
+	if dump {
+		reqDetails, _ := httputil.DumpRequest(req, true)
+		Log("debug", fmt.Sprintf("Request Details:\n%s", string(reqDetails)))
+	}
 
 	resp, err := client.Do(req)
-	respDetails, _ := httputil.DumpResponse(resp, true)
-	Log("debug", fmt.Sprintf("Response Details:\n%s", string(respDetails)))
-	return resp, err
+	if err != nil {
+		return nil, err
+	}
+	if dump {
+		respDetails, _ := httputil.DumpResponse(resp, true)
+		Log("debug", fmt.Sprintf("Response Details:\n%s", string(respDetails)))
+	}
+	return resp, nil
 }
 
 func GetHttpResponseJson(resp *http.Response, result interface{}) error {
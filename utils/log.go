@@ -51,8 +51,18 @@ func InitLogger(filename string, levelStr string) {
 	Logger = logger
 }
 
-// Log log message based on log level
+// LogEnabled Whether messages of the log level are logged
+func LogEnabled(levelStr string) bool {
+	return Logger != nil && Logger.IsLevelEnabled(GetLogLevel(levelStr))
+}
+
+// Log log message based on log level, credentials in the message are redacted
 func Log(levelStr string, message string) {
+	// Redaction is costly for request dumps, skip it if the message is dropped anyway
+	if !LogEnabled(levelStr) {
+		return
+	}
+	message = Redact(message)
 	level := GetLogLevel(levelStr)
 	switch level {
 	case log.PanicLevel:
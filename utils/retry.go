@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy How a failed request is retried, the zero value sends each request only once.
+// Idempotent requests and requests marked by MarkIdempotent are retried on network errors and
+// responses with status code 429, 502, 503 or 504
+type RetryPolicy struct {
+	// MaxAttempts Maximum number of attempts including the first one, 0 means no limit if MaxElapsed is set
+	MaxAttempts int
+	// Interval Wait time before the first retry
+	Interval time.Duration
+	// MaxInterval Upper bound of the wait time between attempts, 0 means no bound
+	MaxInterval time.Duration
+	// Multiplier Factor the wait time grows by after each attempt, 2 as default
+	Multiplier float64
+	// Jitter Fraction of the wait time randomly added or subtracted, e.g. 0.2 for +/-20%
+	Jitter float64
+	// MaxElapsed Give up once the next attempt would start later than this since the first one, 0 means no limit
+	MaxElapsed time.Duration
+}
+
+// DefaultRetryPolicy A policy suitable for riding out array failovers and busy management servers
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		Interval:    500 * time.Millisecond,
+		MaxInterval: 10 * time.Second,
+		Multiplier:  2,
+		Jitter:      0.2,
+		MaxElapsed:  30 * time.Second,
+	}
+}
+
+// Methods which can be sent again without side effects
+var idempotentMethods = map[string]bool{
+	"GET":     true,
+	"HEAD":    true,
+	"OPTIONS": true,
+	"PUT":     true,
+	"DELETE":  true,
+}
+
+// Status codes of transient failures
+var retryStatusCodes = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// MarkIdempotent Mark a request such as a read only POST as safe to retry.
+// net/http treats a request with a nil Idempotency-Key header as idempotent without sending the header
+func MarkIdempotent(req *http.Request) {
+	req.Header["Idempotency-Key"] = nil
+}
+
+func isIdempotent(req *http.Request) bool {
+	if idempotentMethods[req.Method] {
+		return true
+	}
+	_, ok := req.Header["Idempotency-Key"]
+	return ok
+}
+
+// Wait time before the next attempt, attempt starts from 1
+func (policy RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := policy.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	wait := float64(policy.Interval) * math.Pow(multiplier, float64(attempt-1))
+	if policy.MaxInterval > 0 && wait > float64(policy.MaxInterval) {
+		wait = float64(policy.MaxInterval)
+	}
+	if policy.Jitter > 0 {
+		wait += wait * policy.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(wait)
+}
+
+// Parse the Retry-After header given as seconds or a HTTP date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// DoHttpRequestWithRetry Send the request and retry transient failures based on the policy,
+// the last response or error is returned once the policy gives up
+func DoHttpRequestWithRetry(client *http.Client, req *http.Request, policy RetryPolicy) (*http.Response, error) {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		resp, err := DoHttpRequest(client, req)
+
+		var reason string
+		var certErr *tls.CertificateVerificationError
+		switch {
+		case err != nil:
+			// Do not retry once the caller gives up or the server is not trusted
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.As(err, &certErr) || errors.Is(err, ErrCertificateNotPinned) {
+				return resp, err
+			}
+			reason = err.Error()
+		case retryStatusCodes[resp.StatusCode]:
+			reason = fmt.Sprintf("status code %d", resp.StatusCode)
+		default:
+			return resp, err
+		}
+
+		if !isIdempotent(req) {
+			return resp, err
+		}
+		if policy.MaxAttempts <= 0 && policy.MaxElapsed <= 0 {
+			return resp, err
+		}
+		if policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts {
+			return resp, err
+		}
+
+		wait := policy.backoff(attempt)
+		if resp != nil {
+			if after, ok := retryAfter(resp); ok {
+				wait = after
+			}
+		}
+		if policy.MaxElapsed > 0 && time.Since(start)+wait > policy.MaxElapsed {
+			return resp, err
+		}
+
+		// The body has been consumed by the previous attempt
+		if req.GetBody != nil {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return resp, err
+			}
+			req.Body = body
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		Log("warning", fmt.Sprintf("Retry %s %s in %s after attempt %d failed due to %s", req.Method, req.URL.Path, wait, attempt, reason))
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
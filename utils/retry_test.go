@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestDoHttpRequestWithRetry(t *testing.T) {
+	Logger = log.New()
+	Logger.SetOutput(ioutil.Discard)
+
+	var attempts int
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if attempts < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	client := InitHttpClient()
+	policy := RetryPolicy{MaxAttempts: 5, Interval: time.Millisecond, Jitter: 0.5}
+	send := func(method string, idempotent bool, policy RetryPolicy) int {
+		attempts = 0
+		bodies = nil
+		req, err := InitHttpRequest(method, ts.URL, map[string]string{"key": "value"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if idempotent {
+			MarkIdempotent(req)
+		}
+		resp, err := DoHttpRequestWithRetry(&client, req, policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := send("GET", false, policy); code != http.StatusOK || attempts != 3 {
+		t.Errorf("GET: expect 200 after 3 attempts, got %d after %d", code, attempts)
+	}
+
+	if code := send("POST", false, policy); code != http.StatusServiceUnavailable || attempts != 1 {
+		t.Errorf("POST: expect 503 after 1 attempt, got %d after %d", code, attempts)
+	}
+
+	if code := send("POST", true, policy); code != http.StatusOK || attempts != 3 {
+		t.Errorf("Safe POST: expect 200 after 3 attempts, got %d after %d", code, attempts)
+	}
+	for _, body := range bodies {
+		if !strings.Contains(body, "value") {
+			t.Errorf("Payload is lost on retry: %q", body)
+		}
+	}
+
+	if code := send("GET", false, RetryPolicy{MaxAttempts: 2, Interval: time.Millisecond}); code != http.StatusServiceUnavailable || attempts != 2 {
+		t.Errorf("Expect 503 after 2 attempts, got %d after %d", code, attempts)
+	}
+
+	if code := send("GET", false, RetryPolicy{}); code != http.StatusServiceUnavailable || attempts != 1 {
+		t.Errorf("Expect no retry with the zero policy, got %d after %d", code, attempts)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{Interval: 100 * time.Millisecond, MaxInterval: time.Second, Multiplier: 3}
+	expected := []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 900 * time.Millisecond, time.Second}
+	for i, e := range expected {
+		if wait := policy.backoff(i + 1); wait != e {
+			t.Errorf("Attempt %d: expect %s, got %s", i+1, e, wait)
+		}
+	}
+
+	policy.Jitter = 0.2
+	for i := 0; i < 100; i++ {
+		if wait := policy.backoff(1); wait < 80*time.Millisecond || wait > 120*time.Millisecond {
+			t.Errorf("Jittered wait %s is out of range", wait)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+)
+
+// ErrCertificateNotPinned The server certificate does not match any pinned fingerprint
+var ErrCertificateNotPinned = errors.New("Server certificate does not match any pinned fingerprint")
+
+// TLSOptions TLS settings of the connection to an array, the server certificate is verified against the system
+// CA pool unless a CA bundle, a fingerprint or the insecure mode is specified
+type TLSOptions struct {
+	// CAFile PEM encoded CA bundle used to verify the server certificate
+	CAFile string
+	// CAPEM PEM encoded CA certificates used together with CAFile
+	CAPEM []byte
+	// Fingerprints SHA-256 fingerprints of trusted server certificates in hex, colons are allowed.
+	// Self-signed certificates are accepted if their fingerprints match and no CA is specified
+	Fingerprints []string
+	// CertFile and KeyFile PEM encoded client certificate and key
+	CertFile string
+	KeyFile  string
+	// Insecure Skip the verification of the server certificate
+	Insecure bool
+}
+
+// Config Build a tls.Config based on the options
+func (opts TLSOptions) Config() (*tls.Config, error) {
+	config := &tls.Config{}
+
+	if opts.Insecure {
+		config.InsecureSkipVerify = true
+	}
+
+	if opts.CAFile != "" || len(opts.CAPEM) > 0 {
+		pool := x509.NewCertPool()
+		pems := [][]byte{opts.CAPEM}
+		if opts.CAFile != "" {
+			pem, err := ioutil.ReadFile(opts.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("Fail to read CA bundle %s: %s", opts.CAFile, err.Error())
+			}
+			pems = append(pems, pem)
+		}
+		for _, pem := range pems {
+			if len(pem) > 0 && !pool.AppendCertsFromPEM(pem) {
+				return nil, errors.New("No valid certificate is found in the CA bundle")
+			}
+		}
+		config.RootCAs = pool
+	}
+
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Fail to load client certificate: %s", err.Error())
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(opts.Fingerprints) > 0 {
+		pins := map[string]bool{}
+		for _, fp := range opts.Fingerprints {
+			fp = strings.ToLower(strings.Replace(strings.TrimSpace(fp), ":", "", -1))
+			if len(fp) != sha256.Size*2 {
+				return nil, fmt.Errorf("Invalid SHA-256 fingerprint %s", fp)
+			}
+			pins[fp] = true
+		}
+		// Without a CA the chain cannot be verified, the pinned fingerprint is the only trust anchor
+		if config.RootCAs == nil {
+			config.InsecureSkipVerify = true
+		}
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 || !pins[CertFingerprint(rawCerts[0])] {
+				return ErrCertificateNotPinned
+			}
+			return nil
+		}
+	}
+
+	return config, nil
+}
+
+// CertFingerprint SHA-256 fingerprint of a DER encoded certificate in hex
+func CertFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// InitHttpClientWithTLS Init a HTTP client with the provided TLS options
+func InitHttpClientWithTLS(opts TLSOptions) (http.Client, error) {
+	config, err := opts.Config()
+	if err != nil {
+		return http.Client{}, err
+	}
+	return InitHttpClientWithTLSConfig(config), nil
+}
+
+// InitHttpClientWithTLSConfig Init a HTTP client with the provided TLS configuration
+func InitHttpClientWithTLSConfig(config *tls.Config) http.Client {
+	cookieJar, _ := cookiejar.New(nil)
+	tr := &http.Transport{TLSClientConfig: config}
+	return http.Client{Transport: tr, Jar: cookieJar, Timeout: DefaultHttpTimeout}
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestInitHttpClientWithTLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	cert := ts.Certificate()
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	fingerprint := CertFingerprint(cert.Raw)
+
+	cases := []struct {
+		name string
+		opts TLSOptions
+		ok   bool
+	}{
+		{"default", TLSOptions{}, false},
+		{"insecure", TLSOptions{Insecure: true}, true},
+		{"ca", TLSOptions{CAPEM: caPEM}, true},
+		{"pinned", TLSOptions{Fingerprints: []string{strings.ToUpper(fingerprint)}}, true},
+		{"pinned with ca", TLSOptions{CAPEM: caPEM, Fingerprints: []string{fingerprint}}, true},
+		{"wrong pin", TLSOptions{Fingerprints: []string{strings.Repeat("0", 64)}}, false},
+	}
+	for _, c := range cases {
+		client, err := InitHttpClientWithTLS(c.opts)
+		if err != nil {
+			t.Fatalf("%s: %s", c.name, err.Error())
+		}
+		resp, err := client.Get(ts.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if (err == nil) != c.ok {
+			t.Errorf("%s: expect success %v, got error %v", c.name, c.ok, err)
+		}
+	}
+
+	// A pin mismatch is permanent and must not be retried
+	Logger = log.New()
+	Logger.SetOutput(ioutil.Discard)
+	client, err := InitHttpClientWithTLS(TLSOptions{Fingerprints: []string{strings.Repeat("0", 64)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := InitHttpRequest("GET", ts.URL, nil)
+	start := time.Now()
+	_, err = DoHttpRequestWithRetry(&client, req, RetryPolicy{MaxAttempts: 3, Interval: time.Second})
+	if !errors.Is(err, ErrCertificateNotPinned) || time.Since(start) > time.Second {
+		t.Errorf("Expect ErrCertificateNotPinned without retry, got %v", err)
+	}
+
+	if _, err := InitHttpClientWithTLS(TLSOptions{CAPEM: []byte("invalid")}); err == nil {
+		t.Error("Expect an error for an invalid CA bundle")
+	}
+	if _, err := InitHttpClientWithTLS(TLSOptions{Fingerprints: []string{"abc"}}); err == nil {
+		t.Error("Expect an error for an invalid fingerprint")
+	}
+}